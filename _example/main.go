@@ -7,6 +7,7 @@ import (
 
 	"firebase.google.com/go/v4/messaging"
 	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/condition"
 )
 
 func main() {
@@ -58,7 +59,13 @@ func main() {
 	// Send with condition
 	// Define a condition which will send to devices which are subscribed
 	// to either the Google stock or the tech industry topics.
-	condition := "'stock-GOOG' in topics || 'industry-tech' in topics"
+	cond, err := condition.Render(condition.Or(
+		condition.InTopic("stock-GOOG"),
+		condition.InTopic("industry-tech"),
+	))
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// See documentation on defining a message payload.
 	message := &messaging.Message{
@@ -66,7 +73,7 @@ func main() {
 			"score": "850",
 			"time":  "2:45",
 		},
-		Condition: condition,
+		Condition: cond,
 	}
 
 	resp, err = client.Send(
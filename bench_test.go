@@ -0,0 +1,109 @@
+package fcm_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"firebase.google.com/go/v4/messaging"
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+)
+
+// newBenchClient starts a fake FCM server with recording disabled and returns
+// a Client wired to it.
+func newBenchClient(b *testing.B) *fcm.Client {
+	b.Helper()
+	srv := fcmtest.NewServer()
+	srv.SetRecording(false)
+	b.Cleanup(srv.Close)
+
+	client, err := fcm.NewClient(
+		context.Background(),
+		fcm.WithProjectID("bench"),
+		fcm.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	return client
+}
+
+func BenchmarkSend(b *testing.B) {
+	for _, batch := range []int{1, 10, 100, 500} {
+		b.Run("batch="+strconv.Itoa(batch), func(b *testing.B) {
+			client := newBenchClient(b)
+			msgs := make([]*messaging.Message, batch)
+			for i := range msgs {
+				msgs[i] = &messaging.Message{
+					Token: fmt.Sprintf("token-%d", i),
+					Data:  map[string]string{"foo": "bar"},
+				}
+			}
+
+			sampler := fcmtest.StartGoroutineSampler()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := client.Send(context.Background(), msgs...); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+			b.StopTimer()
+			peak := sampler.Stop()
+
+			b.ReportMetric(float64(b.N*batch)/b.Elapsed().Seconds(), "msgs/s")
+			b.ReportMetric(float64(peak), "peak-goroutines")
+		})
+	}
+}
+
+func BenchmarkSendMulticast(b *testing.B) {
+	for _, batch := range []int{1, 100, 500} {
+		b.Run("tokens="+strconv.Itoa(batch), func(b *testing.B) {
+			client := newBenchClient(b)
+			tokens := make([]string, batch)
+			for i := range tokens {
+				tokens[i] = fmt.Sprintf("token-%d", i)
+			}
+			msg := &messaging.MulticastMessage{
+				Tokens: tokens,
+				Data:   map[string]string{"foo": "bar"},
+			}
+
+			sampler := fcmtest.StartGoroutineSampler()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := client.SendMulticast(context.Background(), msg); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+			b.StopTimer()
+			peak := sampler.Stop()
+
+			b.ReportMetric(float64(b.N*batch)/b.Elapsed().Seconds(), "msgs/s")
+			b.ReportMetric(float64(peak), "peak-goroutines")
+		})
+	}
+}
+
+func BenchmarkSendParallel(b *testing.B) {
+	client := newBenchClient(b)
+	msg := &messaging.Message{
+		Token: "token",
+		Data:  map[string]string{"foo": "bar"},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := client.Send(context.Background(), msg); err != nil {
+				b.Errorf("unexpected error: %v", err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,266 @@
+// Package builder provides a fluent, validating way to assemble a
+// messaging.Message for the go-fcm Client.
+//
+//	msg, err := builder.ToToken(token).
+//		Notification("Hello", "World").
+//		Data("order_id", "42").
+//		AndroidHighPriority().
+//		APNsSound("default").
+//		TTL(time.Hour).
+//		Build()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	resp, err := client.Send(ctx, msg)
+package builder
+
+import (
+	"errors"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	fcm "github.com/appleboy/go-fcm"
+)
+
+// Builder assembles a messaging.Message step by step. Setters never fail;
+// problems are collected and reported by Build.
+type Builder struct {
+	msg   *messaging.Message
+	errs  []error
+	clock fcm.Clock
+}
+
+// ToToken starts a message addressed to a single registration token.
+func ToToken(token string) *Builder {
+	return &Builder{msg: &messaging.Message{Token: token}}
+}
+
+// ToTopic starts a message addressed to every device subscribed to topic.
+func ToTopic(topic string) *Builder {
+	return &Builder{msg: &messaging.Message{Topic: topic}}
+}
+
+// ToCondition starts a message addressed to the devices matching a topic
+// condition such as "'a' in topics && 'b' in topics".
+func ToCondition(condition string) *Builder {
+	return &Builder{msg: &messaging.Message{Condition: condition}}
+}
+
+// Notification sets the cross-platform notification title and body.
+func (b *Builder) Notification(title, body string) *Builder {
+	b.notification().Title = title
+	b.notification().Body = body
+	return b
+}
+
+// Image sets the cross-platform notification image URL.
+func (b *Builder) Image(url string) *Builder {
+	b.notification().ImageURL = url
+	return b
+}
+
+// Data adds a key/value pair to the data payload.
+func (b *Builder) Data(key, value string) *Builder {
+	if b.msg.Data == nil {
+		b.msg.Data = map[string]string{}
+	}
+	b.msg.Data[key] = value
+	return b
+}
+
+// DataMap adds every entry of data to the data payload.
+func (b *Builder) DataMap(data map[string]string) *Builder {
+	for k, v := range data {
+		b.Data(k, v)
+	}
+	return b
+}
+
+// AndroidHighPriority delivers the message with Android high priority.
+func (b *Builder) AndroidHighPriority() *Builder {
+	b.android().Priority = "high"
+	return b
+}
+
+// AndroidChannel sets the Android notification channel ID.
+func (b *Builder) AndroidChannel(channelID string) *Builder {
+	b.androidNotification().ChannelID = channelID
+	return b
+}
+
+// AndroidCollapseKey sets the Android collapse key.
+func (b *Builder) AndroidCollapseKey(key string) *Builder {
+	b.android().CollapseKey = key
+	return b
+}
+
+// APNsSound sets the sound played by iOS when the notification arrives.
+func (b *Builder) APNsSound(sound string) *Builder {
+	b.aps().Sound = sound
+	return b
+}
+
+// APNsBadge sets the iOS app icon badge number.
+func (b *Builder) APNsBadge(badge int) *Builder {
+	b.aps().Badge = &badge
+	return b
+}
+
+// APNsCategory sets the iOS notification category.
+func (b *Builder) APNsCategory(category string) *Builder {
+	b.aps().Category = category
+	return b
+}
+
+// APNsAps sets a raw key in the aps dictionary, for fields the SDK does not
+// model such as "interruption-level".
+func (b *Builder) APNsAps(key string, value any) *Builder {
+	aps := b.aps()
+	if aps.CustomData == nil {
+		aps.CustomData = map[string]any{}
+	}
+	aps.CustomData[key] = value
+	return b
+}
+
+// APNsPayload sets a custom top-level key in the APNs payload, next to aps.
+func (b *Builder) APNsPayload(key string, value any) *Builder {
+	payload := b.apnsPayload()
+	if payload.CustomData == nil {
+		payload.CustomData = map[string]any{}
+	}
+	payload.CustomData[key] = value
+	return b
+}
+
+// APNsHeader sets an APNs request header such as "apns-priority".
+func (b *Builder) APNsHeader(key, value string) *Builder {
+	apns := b.apns()
+	if apns.Headers == nil {
+		apns.Headers = map[string]string{}
+	}
+	apns.Headers[key] = value
+	return b
+}
+
+// WebpushLink sets the URL opened when the web notification is clicked.
+func (b *Builder) WebpushLink(link string) *Builder {
+	webpush := b.webpush()
+	if webpush.FCMOptions == nil {
+		webpush.FCMOptions = &messaging.WebpushFCMOptions{}
+	}
+	webpush.FCMOptions.Link = link
+	return b
+}
+
+// WebpushHeader sets a Webpush protocol header such as "Urgency".
+func (b *Builder) WebpushHeader(key, value string) *Builder {
+	webpush := b.webpush()
+	if webpush.Headers == nil {
+		webpush.Headers = map[string]string{}
+	}
+	webpush.Headers[key] = value
+	return b
+}
+
+// TTL sets how long FCM keeps the message while the device is offline, on
+// Android, APNs and Webpush alike. See fcm.ExpiresIn.
+func (b *Builder) TTL(d time.Duration) *Builder {
+	return b.Apply(fcm.ExpiresIn(d))
+}
+
+// ExpiresAt drops the message if it cannot be delivered before t. See
+// fcm.ExpiresAt.
+func (b *Builder) ExpiresAt(t time.Time) *Builder {
+	return b.Apply(fcm.ExpiresAt(t))
+}
+
+// CollapseKey lets newer messages with the same key replace undelivered older
+// ones on every platform. See fcm.CollapseKey.
+func (b *Builder) CollapseKey(key string) *Builder {
+	return b.Apply(fcm.CollapseKey(key))
+}
+
+// Clock makes TTL, ExpiresAt and Apply take the current time from clock, such
+// as the Clock passed to fcm.WithClock, instead of the system clock. Set it
+// before those calls.
+func (b *Builder) Clock(clock fcm.Clock) *Builder {
+	b.clock = clock
+	return b
+}
+
+// Apply applies cross-platform message options at the current time of the
+// Builder's Clock.
+func (b *Builder) Apply(opts ...fcm.MessageOption) *Builder {
+	now := time.Now()
+	if b.clock != nil {
+		now = b.clock.Now()
+	}
+	if err := fcm.ApplyMessageOptionsAt(b.msg, now, opts...); err != nil {
+		b.errs = append(b.errs, err)
+	}
+	return b
+}
+
+// Build validates the assembled message and returns it, ready for
+// Client.Send.
+func (b *Builder) Build() (*messaging.Message, error) {
+	errs := append([]error(nil), b.errs...)
+	errs = append(errs, fcm.ValidateTarget(b.msg), fcm.ValidateData(b.msg.Data), fcm.ValidateSize(b.msg))
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return b.msg, nil
+}
+
+func (b *Builder) notification() *messaging.Notification {
+	if b.msg.Notification == nil {
+		b.msg.Notification = &messaging.Notification{}
+	}
+	return b.msg.Notification
+}
+
+func (b *Builder) android() *messaging.AndroidConfig {
+	if b.msg.Android == nil {
+		b.msg.Android = &messaging.AndroidConfig{}
+	}
+	return b.msg.Android
+}
+
+func (b *Builder) androidNotification() *messaging.AndroidNotification {
+	android := b.android()
+	if android.Notification == nil {
+		android.Notification = &messaging.AndroidNotification{}
+	}
+	return android.Notification
+}
+
+func (b *Builder) apns() *messaging.APNSConfig {
+	if b.msg.APNS == nil {
+		b.msg.APNS = &messaging.APNSConfig{}
+	}
+	return b.msg.APNS
+}
+
+func (b *Builder) apnsPayload() *messaging.APNSPayload {
+	apns := b.apns()
+	if apns.Payload == nil {
+		apns.Payload = &messaging.APNSPayload{}
+	}
+	return apns.Payload
+}
+
+func (b *Builder) aps() *messaging.Aps {
+	payload := b.apnsPayload()
+	if payload.Aps == nil {
+		payload.Aps = &messaging.Aps{}
+	}
+	return payload.Aps
+}
+
+func (b *Builder) webpush() *messaging.WebpushConfig {
+	if b.msg.Webpush == nil {
+		b.msg.Webpush = &messaging.WebpushConfig{}
+	}
+	return b.msg.Webpush
+}
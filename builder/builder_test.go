@@ -0,0 +1,111 @@
+package builder_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/builder"
+	"github.com/appleboy/go-fcm/fcmtest"
+)
+
+func TestBuildAndSend(t *testing.T) {
+	msg, err := builder.ToToken("device-token").
+		Notification("Hello", "World").
+		Data("order_id", "42").
+		AndroidHighPriority().
+		APNsSound("default").
+		APNsAps("interruption-level", "active").
+		WebpushLink("https://example.com").
+		TTL(time.Hour).
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if msg.Android.Priority != "high" || *msg.Android.TTL != time.Hour {
+		t.Fatalf("unexpected android config: %+v", msg.Android)
+	}
+	if msg.APNS.Payload.Aps.Sound != "default" || msg.APNS.Payload.Aps.CustomData["interruption-level"] != "active" {
+		t.Fatalf("unexpected aps: %+v", msg.APNS.Payload.Aps)
+	}
+	if msg.Webpush.Headers["TTL"] != "3600" || msg.APNS.Headers["apns-expiration"] == "" {
+		t.Fatalf("ttl not applied to every platform: %+v %+v", msg.Webpush.Headers, msg.APNS.Headers)
+	}
+
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+	client, err := fcm.NewClient(
+		context.Background(),
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := client.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.SuccessCount != 1 {
+		t.Fatalf("expected success, got %v", resp.Responses[0].Error)
+	}
+}
+
+func TestBuildWithClock(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	msg, err := builder.ToTopic("news").
+		Clock(fcmtest.NewClock(now)).
+		TTL(time.Hour).
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := msg.APNS.Headers["apns-expiration"], strconv.FormatInt(now.Add(time.Hour).Unix(), 10); got != want {
+		t.Fatalf("apns-expiration = %s, want %s", got, want)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *builder.Builder
+		want    string
+	}{
+		{
+			name:    "missing target",
+			builder: builder.ToToken("").Data("foo", "bar"),
+			want:    "exactly one of token, topic or condition",
+		},
+		{
+			name:    "reserved key",
+			builder: builder.ToTopic("news").Data("google.sent_time", "1"),
+			want:    `"google.sent_time"`,
+		},
+		{
+			name:    "oversized",
+			builder: builder.ToTopic("news").Data("blob", strings.Repeat("x", 5000)),
+			want:    "exceeding the 4096 byte limit",
+		},
+		{
+			name:    "negative ttl",
+			builder: builder.ToTopic("news").TTL(-time.Second),
+			want:    "must be between 0 and",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := tt.builder.Build()
+			if err == nil {
+				t.Fatalf("expected error, got message %+v", msg)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
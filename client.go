@@ -2,19 +2,27 @@ package fcm
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/messaging"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 )
 
 var scopes = []string{
 	"https://www.googleapis.com/auth/firebase.messaging",
 }
 
+// maxBatchSize is the largest number of messages the Firebase SDK accepts in
+// a single SendEach call.
+const maxBatchSize = 500
+
 // Client abstracts the interaction between the application server and the
 // FCM server via the Firebase Cloud Messaging HTTP v1 API. Authenticate it with
 // service-account credentials (WithCredentialsFile / WithCredentialsJSON), an
@@ -38,17 +46,31 @@ type Client struct {
 	tokenSource     oauth2.TokenSource
 	credentialsJSON []byte // credentialsJSON is the JSON representation of the service account credentials.
 	debug           bool
+	clock           Clock
+	preflight       bool
+	tokenStore      TokenStore
+	removeInvalid   bool
+	staleness       *StalenessPolicy
+	dedupTokens     bool
+	iidEndpoint     string
+	groupEndpoint   string
+	authClient      *http.Client
+	senderID        string
+	topicIndex      *TopicIndex
 }
 
 // NewClient creates a new Firebase Cloud Messaging Client, applying the given
 // options and using the default endpoint and http client unless overridden.
 func NewClient(ctx context.Context, opts ...Option) (*Client, error) {
-	c := &Client{}
+	c := &Client{clock: realClock{}}
 	for _, o := range opts {
 		if err := o(c); err != nil {
 			return nil, err
 		}
 	}
+	if _, ok := c.tokenStore.(TokenActivityStore); c.staleness != nil && !ok {
+		return nil, errors.New("staleness policy requires a TokenActivityStore")
+	}
 
 	var conf *firebase.Config
 	if c.serviceAccount != "" || c.projectID != "" {
@@ -110,6 +132,14 @@ func NewClient(ctx context.Context, opts ...Option) (*Client, error) {
 		c.options = append(c.options, option.WithHTTPClient(newHTTPClient(transport)))
 	}
 
+	// Authorize the Instance ID and device group calls the SDK does not
+	// cover with the same credentials and transport as the Firebase client.
+	var err error
+	c.authClient, _, err = htransport.NewClient(ctx, slices.Concat(c.options, []option.ClientOption{option.WithScopes(scopes...)})...)
+	if err != nil {
+		return nil, err
+	}
+
 	app, err := firebase.NewApp(ctx, conf, c.options...)
 	if err != nil {
 		return nil, err
@@ -129,28 +159,130 @@ func NewClient(ctx context.Context, opts ...Option) (*Client, error) {
 // non-nil error is returned only when the batch as a whole cannot be sent, not
 // when individual messages fail, so callers must inspect the response to detect
 // per-message errors.
+//
+// More than 500 messages are sent in consecutive chunks of 500 and the results
+// merged in input order. If a chunk cannot be sent, the responses of the chunks
+// already sent are returned together with the error.
+//
+// With a TokenStore attached, the Token targets FCM reports as dead are pruned
+// from it; an error updating the store is returned along with the response.
 func (c *Client) Send(
 	ctx context.Context,
 	message ...*messaging.Message,
 ) (*messaging.BatchResponse, error) {
-	return c.client.SendEach(ctx, message)
+	if c.preflight {
+		if err := validateMessages(message); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := sendChunked(ctx, message, c.client.SendEach)
+	if c.tokenStore != nil {
+		err = errors.Join(err, c.trackTokens(ctx, message, resp))
+	}
+	return resp, err
 }
 
 // SendDryRun sends the messages in the given array via Firebase Cloud Messaging in the
-// dry run (validation only) mode.
+// dry run (validation only) mode, chunking them like Send.
 func (c *Client) SendDryRun(
 	ctx context.Context,
 	message ...*messaging.Message,
 ) (*messaging.BatchResponse, error) {
-	return c.client.SendEachDryRun(ctx, message)
+	if c.preflight {
+		if err := validateMessages(message); err != nil {
+			return nil, err
+		}
+	}
+	return sendChunked(ctx, message, c.client.SendEachDryRun)
+}
+
+// sendChunked calls send with at most maxBatchSize messages at a time and
+// merges the batch responses in input order.
+func sendChunked(
+	ctx context.Context,
+	messages []*messaging.Message,
+	send func(context.Context, []*messaging.Message) (*messaging.BatchResponse, error),
+) (*messaging.BatchResponse, error) {
+	if len(messages) <= maxBatchSize {
+		return send(ctx, messages)
+	}
+
+	merged := &messaging.BatchResponse{
+		Responses: make([]*messaging.SendResponse, 0, len(messages)),
+	}
+	for start := 0; start < len(messages); start += maxBatchSize {
+		end := min(start+maxBatchSize, len(messages))
+		resp, err := send(ctx, messages[start:end])
+		if err != nil {
+			return merged, fmt.Errorf("cannot send messages %d to %d: %w", start, end-1, err)
+		}
+		merged.Responses = append(merged.Responses, resp.Responses...)
+		merged.SuccessCount += resp.SuccessCount
+		merged.FailureCount += resp.FailureCount
+	}
+	return merged, nil
 }
 
 // SendMulticast sends the given multicast message to all the FCM registration tokens specified.
+// With a TokenStore attached, the tokens FCM reports as dead are pruned from
+// it like in Send, and a StalenessPolicy is applied first if one is set.
+//
+// With WithTokenDedup, the tokens are trimmed and deduplicated before sending
+// and the responses mapped back to every input index.
 func (c *Client) SendMulticast(
 	ctx context.Context,
 	message *messaging.MulticastMessage,
 ) (*messaging.BatchResponse, error) {
-	return c.client.SendEachForMulticast(ctx, message)
+	if c.dedupTokens && message != nil {
+		d := DedupTokens(message.Tokens)
+		if len(d.Unique) == 0 && len(d.Index) > 0 {
+			return d.expandBatch(nil), nil
+		}
+		unique := *message
+		unique.Tokens = d.Unique
+		resp, err := c.sendMulticast(ctx, &unique)
+		return d.expandBatch(resp), err
+	}
+	return c.sendMulticast(ctx, message)
+}
+
+func (c *Client) sendMulticast(
+	ctx context.Context,
+	message *messaging.MulticastMessage,
+) (*messaging.BatchResponse, error) {
+	if c.preflight && message != nil {
+		if err := ValidateMulticast(message); err != nil {
+			return nil, err
+		}
+	}
+	var excluded []*messaging.SendResponse
+	if message != nil {
+		var err error
+		message, excluded, err = c.applyStaleness(ctx, message)
+		if err != nil {
+			return nil, err
+		}
+		if message == nil {
+			return mergeExcluded(nil, excluded), nil
+		}
+	}
+	resp, err := c.client.SendEachForMulticast(ctx, message)
+	if err != nil || message == nil {
+		return resp, err
+	}
+	if c.tokenStore != nil {
+		messages := make([]*messaging.Message, len(message.Tokens))
+		for i, token := range message.Tokens {
+			m := multicastPayload(message)
+			m.Token = token
+			messages[i] = m
+		}
+		err = c.trackTokens(ctx, messages, resp)
+	}
+	if excluded != nil {
+		resp = mergeExcluded(resp, excluded)
+	}
+	return resp, err
 }
 
 // SendMulticastDryRun sends the given multicast message to all the specified FCM registration
@@ -159,27 +291,61 @@ func (c *Client) SendMulticastDryRun(
 	ctx context.Context,
 	message *messaging.MulticastMessage,
 ) (*messaging.BatchResponse, error) {
+	if c.preflight && message != nil {
+		if err := ValidateMulticast(message); err != nil {
+			return nil, err
+		}
+	}
 	return c.client.SendEachForMulticastDryRun(ctx, message)
 }
 
 // SubscribeTopic subscribes a list of registration tokens to a topic.
 //
-// The tokens list must not be empty, and have at most 1000 tokens.
+// The tokens list must not be empty, and have at most 1000 tokens. With
+// WithTokenDedup, the tokens are trimmed and deduplicated first and the errors
+// reported at every input index. With a TopicIndex attached, the tokens
+// subscribed successfully are recorded in it.
 func (c *Client) SubscribeTopic(
 	ctx context.Context,
 	tokens []string,
 	topic string,
 ) (*messaging.TopicManagementResponse, error) {
-	return c.client.SubscribeToTopic(ctx, tokens, topic)
+	return c.manageTopic(ctx, tokens, topic, true, c.client.SubscribeToTopic)
 }
 
 // UnsubscribeTopic unsubscribes a list of registration tokens from a topic.
 //
-// The tokens list must not be empty, and have at most 1000 tokens.
+// The tokens list must not be empty, and have at most 1000 tokens. Tokens are
+// deduplicated and recorded like in SubscribeTopic.
 func (c *Client) UnsubscribeTopic(
 	ctx context.Context,
 	tokens []string,
 	topic string,
 ) (*messaging.TopicManagementResponse, error) {
-	return c.client.UnsubscribeFromTopic(ctx, tokens, topic)
+	return c.manageTopic(ctx, tokens, topic, false, c.client.UnsubscribeFromTopic)
+}
+
+func (c *Client) manageTopic(
+	ctx context.Context,
+	tokens []string,
+	topic string,
+	subscribe bool,
+	call func(context.Context, []string, string) (*messaging.TopicManagementResponse, error),
+) (*messaging.TopicManagementResponse, error) {
+	var d TokenDedup
+	if c.dedupTokens {
+		d = DedupTokens(tokens)
+		if len(d.Unique) == 0 && len(d.Index) > 0 {
+			return d.expandTopic(nil), nil
+		}
+		tokens = d.Unique
+	}
+	resp, err := call(ctx, tokens, topic)
+	if err == nil && c.topicIndex != nil {
+		c.topicIndex.record(tokens, topic, resp, subscribe)
+	}
+	if c.dedupTokens {
+		resp = d.expandTopic(resp)
+	}
+	return resp, err
 }
@@ -2,8 +2,12 @@ package fcm
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 
@@ -251,3 +255,80 @@ func TestTransportAuthCombos(t *testing.T) {
 		}
 	})
 }
+
+func TestSendChunked(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Message struct {
+				Token string `json:"token"`
+			} `json:"message"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(req.Message.Token, "bad") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"code":400,"status":"INVALID_ARGUMENT","message":"bad token"}}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"name": "projects/test/messages/" + req.Message.Token})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(
+		context.Background(),
+		WithEndpoint(server.URL),
+		WithProjectID("test"),
+		WithTokenSource(&MockTokenSource{AccessToken: "test-token"}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	messages := make([]*messaging.Message, 1200)
+	for i := range messages {
+		messages[i] = &messaging.Message{Token: fmt.Sprintf("token-%d", i)}
+	}
+	messages[3].Token = "bad-3"
+	messages[777].Token = "bad-777"
+
+	t.Run("merges chunks in input order", func(t *testing.T) {
+		resp, err := client.Send(context.Background(), messages...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(resp.Responses) != 1200 || resp.SuccessCount != 1198 || resp.FailureCount != 2 {
+			t.Fatalf("unexpected counts: responses=%d success=%d failure=%d",
+				len(resp.Responses), resp.SuccessCount, resp.FailureCount)
+		}
+		for i, r := range resp.Responses {
+			if i == 3 || i == 777 {
+				if r.Success {
+					t.Fatalf("expected message %d to fail", i)
+				}
+				continue
+			}
+			if want := "projects/test/messages/" + messages[i].Token; r.MessageID != want {
+				t.Fatalf("response %d = %q, want %q", i, r.MessageID, want)
+			}
+		}
+	})
+
+	t.Run("returns partial results when a chunk fails", func(t *testing.T) {
+		broken := slices.Clone(messages)
+		// A message without a target makes the SDK reject the whole third chunk.
+		broken[1100] = &messaging.Message{}
+		resp, err := client.SendDryRun(context.Background(), broken...)
+		if err == nil || !strings.Contains(err.Error(), "cannot send messages 1000 to 1199") {
+			t.Fatalf("expected an error for the third chunk, got %v", err)
+		}
+		if resp == nil || len(resp.Responses) != 1000 || resp.SuccessCount != 998 || resp.FailureCount != 2 {
+			t.Fatalf("expected the first two chunks in the partial response, got %+v", resp)
+		}
+		if resp.Responses[999].MessageID != "projects/test/messages/token-999" {
+			t.Fatalf("unexpected last response: %+v", resp.Responses[999])
+		}
+	})
+}
@@ -0,0 +1,179 @@
+// Command fcm-bench drives Client.Send or Client.SendMulticast against a local
+// fake FCM server and reports throughput, call latency percentiles,
+// allocations and goroutine counts.
+//
+// Usage:
+//
+//	go run ./cmd/fcm-bench -mode send -n 10000 -c 8 -batch 100 -latency 20ms
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+)
+
+type config struct {
+	mode                string
+	total               int
+	concurrency         int
+	batch               int
+	latency             time.Duration
+	maxConnsPerHost     int
+	maxIdleConnsPerHost int
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.mode, "mode", "send", "API to exercise: send or multicast")
+	flag.IntVar(&cfg.total, "n", 10000, "total number of messages to send")
+	flag.IntVar(&cfg.concurrency, "c", 4, "number of concurrent callers")
+	flag.IntVar(&cfg.batch, "batch", 100, "messages per Send call or tokens per multicast (max 500)")
+	flag.DurationVar(&cfg.latency, "latency", 0, "artificial latency added by the fake server to every request")
+	flag.IntVar(&cfg.maxConnsPerHost, "max-conns-per-host", 0, "http.Transport MaxConnsPerHost (0 = unlimited)")
+	flag.IntVar(&cfg.maxIdleConnsPerHost, "max-idle-conns-per-host", 0, "http.Transport MaxIdleConnsPerHost (0 = default)")
+	flag.Parse()
+
+	if cfg.mode != "send" && cfg.mode != "multicast" {
+		log.Fatalf("unknown mode %q", cfg.mode)
+	}
+	if cfg.total <= 0 || cfg.concurrency <= 0 || cfg.batch <= 0 || cfg.batch > 500 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(os.Stdout, cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(w io.Writer, cfg config) error {
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+	srv.SetRecording(false)
+	srv.SetLatency(cfg.latency)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxConnsPerHost = cfg.maxConnsPerHost
+	if cfg.maxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.maxIdleConnsPerHost
+	}
+
+	ctx := context.Background()
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("fcm-bench"),
+		fcm.WithHTTPClient(&http.Client{Transport: srv.Transport(transport)}),
+	)
+	if err != nil {
+		return err
+	}
+
+	calls := (cfg.total + cfg.batch - 1) / cfg.batch
+	jobs := make(chan int, calls)
+	for i := 0; i < calls; i++ {
+		size := cfg.batch
+		if rest := cfg.total - i*cfg.batch; rest < size {
+			size = rest
+		}
+		jobs <- size
+	}
+	close(jobs)
+
+	var (
+		mu        sync.Mutex
+		latencies = make([]time.Duration, 0, calls)
+		failures  atomic.Int64
+		callErrs  atomic.Int64
+	)
+
+	sampler := fcmtest.StartGoroutineSampler()
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for size := range jobs {
+				t := time.Now()
+				resp, err := send(ctx, client, cfg.mode, size)
+				d := time.Since(t)
+				if err != nil {
+					callErrs.Add(1)
+					continue
+				}
+				failures.Add(int64(resp.FailureCount))
+				mu.Lock()
+				latencies = append(latencies, d)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	peak := sampler.Stop()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Fprintf(w, "mode:              %s\n", cfg.mode)
+	fmt.Fprintf(w, "messages:          %d (%d calls, batch %d, concurrency %d)\n", cfg.total, calls, cfg.batch, cfg.concurrency)
+	fmt.Fprintf(w, "elapsed:           %s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "throughput:        %.0f msgs/s\n", float64(cfg.total)/elapsed.Seconds())
+	fmt.Fprintf(w, "call latency:      p50=%s p90=%s p99=%s max=%s\n",
+		percentile(latencies, 0.50), percentile(latencies, 0.90),
+		percentile(latencies, 0.99), percentile(latencies, 1))
+	fmt.Fprintf(w, "allocations:       %d (%.1f per message, %d bytes)\n",
+		after.Mallocs-before.Mallocs,
+		float64(after.Mallocs-before.Mallocs)/float64(cfg.total),
+		after.TotalAlloc-before.TotalAlloc)
+	fmt.Fprintf(w, "peak goroutines:   %d\n", peak)
+	fmt.Fprintf(w, "failed messages:   %d\n", failures.Load())
+	fmt.Fprintf(w, "failed calls:      %d\n", callErrs.Load())
+	return nil
+}
+
+func send(ctx context.Context, client *fcm.Client, mode string, size int) (*messaging.BatchResponse, error) {
+	data := map[string]string{"foo": "bar"}
+	if mode == "multicast" {
+		tokens := make([]string, size)
+		for i := range tokens {
+			tokens[i] = fmt.Sprintf("token-%d", i)
+		}
+		return client.SendMulticast(ctx, &messaging.MulticastMessage{Tokens: tokens, Data: data})
+	}
+
+	msgs := make([]*messaging.Message, size)
+	for i := range msgs {
+		msgs[i] = &messaging.Message{Token: fmt.Sprintf("token-%d", i), Data: data}
+	}
+	return client.Send(ctx, msgs...)
+}
+
+// percentile returns the p-th percentile of the sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p)
+	return sorted[idx].Round(time.Microsecond)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	for _, mode := range []string{"send", "multicast"} {
+		var out bytes.Buffer
+		cfg := config{mode: mode, total: 25, concurrency: 2, batch: 10}
+		if err := run(&out, cfg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		report := out.String()
+		for _, want := range []string{"mode:              " + mode, "(3 calls, batch 10", "failed messages:   0", "failed calls:      0"} {
+			if !strings.Contains(report, want) {
+				t.Fatalf("report does not contain %q:\n%s", want, report)
+			}
+		}
+	}
+}
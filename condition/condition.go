@@ -0,0 +1,199 @@
+// Package condition builds, parses and validates FCM topic condition
+// expressions such as "'stock-GOOG' in topics || 'industry-tech' in topics".
+//
+// Build a condition from code:
+//
+//	expr := condition.Or(
+//		condition.InTopic("stock-GOOG"),
+//		condition.InTopic("industry-tech"),
+//	)
+//	cond, err := condition.Render(expr)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	msg := &messaging.Message{Condition: cond}
+//
+// or parse an existing string with Parse.
+package condition
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxTopics is the largest number of topic references FCM accepts in a
+// single condition.
+const MaxTopics = 5
+
+var topicPattern = regexp.MustCompile(`^[a-zA-Z0-9-_.~%]{1,900}$`)
+
+// Expr is a node of a condition expression. Its String method renders the
+// normalized FCM syntax.
+type Expr interface {
+	String() string
+	expr()
+}
+
+// TopicExpr matches devices subscribed to Name: 'Name' in topics.
+type TopicExpr struct {
+	Name string
+}
+
+// NotExpr matches devices that do not match X.
+type NotExpr struct {
+	X Expr
+}
+
+// AndExpr matches devices that match every operand.
+type AndExpr struct {
+	X []Expr
+}
+
+// OrExpr matches devices that match at least one operand.
+type OrExpr struct {
+	X []Expr
+}
+
+func (TopicExpr) expr() {}
+func (NotExpr) expr()   {}
+func (AndExpr) expr()   {}
+func (OrExpr) expr()    {}
+
+// InTopic returns an expression matching devices subscribed to topic. An
+// optional "/topics/" prefix is dropped.
+func InTopic(topic string) Expr {
+	return TopicExpr{Name: strings.TrimPrefix(topic, "/topics/")}
+}
+
+// Not returns the negation of x.
+func Not(x Expr) Expr {
+	return NotExpr{X: x}
+}
+
+// And returns the conjunction of exprs.
+func And(exprs ...Expr) Expr {
+	return AndExpr{X: exprs}
+}
+
+// Or returns the disjunction of exprs.
+func Or(exprs ...Expr) Expr {
+	return OrExpr{X: exprs}
+}
+
+func (e TopicExpr) String() string {
+	return "'" + e.Name + "' in topics"
+}
+
+func (e NotExpr) String() string {
+	return "!(" + e.X.String() + ")"
+}
+
+func (e AndExpr) String() string {
+	parts := make([]string, len(e.X))
+	for i, x := range e.X {
+		if _, ok := x.(OrExpr); ok {
+			parts[i] = "(" + x.String() + ")"
+		} else {
+			parts[i] = x.String()
+		}
+	}
+	return strings.Join(parts, " && ")
+}
+
+func (e OrExpr) String() string {
+	parts := make([]string, len(e.X))
+	for i, x := range e.X {
+		parts[i] = x.String()
+	}
+	return strings.Join(parts, " || ")
+}
+
+// Topics returns every topic referenced by expr, in order of appearance,
+// including repeats.
+func Topics(expr Expr) []string {
+	var topics []string
+	walk(expr, func(t TopicExpr) { topics = append(topics, t.Name) })
+	return topics
+}
+
+func walk(expr Expr, fn func(TopicExpr)) {
+	switch e := expr.(type) {
+	case TopicExpr:
+		fn(e)
+	case NotExpr:
+		walk(e.X, fn)
+	case AndExpr:
+		for _, x := range e.X {
+			walk(x, fn)
+		}
+	case OrExpr:
+		for _, x := range e.X {
+			walk(x, fn)
+		}
+	}
+}
+
+// Validate checks that expr is well formed, that every topic name is one FCM
+// allows, and that it references at most MaxTopics topics.
+func Validate(expr Expr) error {
+	if err := validate(expr); err != nil {
+		return err
+	}
+	if n := len(Topics(expr)); n > MaxTopics {
+		return fmt.Errorf("condition references %d topics, exceeding the limit of %d", n, MaxTopics)
+	}
+	return nil
+}
+
+func validate(expr Expr) error {
+	switch e := expr.(type) {
+	case nil:
+		return errors.New("condition must not be nil")
+	case TopicExpr:
+		if !topicPattern.MatchString(e.Name) {
+			return fmt.Errorf("invalid topic name: %q", e.Name)
+		}
+	case NotExpr:
+		return validate(e.X)
+	case AndExpr:
+		return validateOperands("&&", e.X)
+	case OrExpr:
+		return validateOperands("||", e.X)
+	default:
+		return fmt.Errorf("unsupported condition node %T", expr)
+	}
+	return nil
+}
+
+func validateOperands(op string, exprs []Expr) error {
+	if len(exprs) == 0 {
+		return fmt.Errorf("%s requires at least one operand", op)
+	}
+	for _, x := range exprs {
+		if err := validate(x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render validates expr and returns its normalized string, ready for
+// messaging.Message.Condition.
+func Render(expr Expr) (string, error) {
+	if err := Validate(expr); err != nil {
+		return "", err
+	}
+	return expr.String(), nil
+}
+
+// Normalize parses and validates a condition string and returns it in
+// normalized form.
+func Normalize(s string) (string, error) {
+	expr, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return Render(expr)
+}
@@ -0,0 +1,107 @@
+package condition
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildAndRender(t *testing.T) {
+	expr := And(
+		InTopic("/topics/stock-GOOG"),
+		Or(InTopic("industry-tech"), Not(InTopic("beta"))),
+	)
+	got, err := Render(expr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "'stock-GOOG' in topics && ('industry-tech' in topics || !('beta' in topics))"
+	if got != want {
+		t.Fatalf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Expr
+		norm string
+	}{
+		{
+			in:   "'stock-GOOG' in topics || 'industry-tech' in topics",
+			want: Or(InTopic("stock-GOOG"), InTopic("industry-tech")),
+			norm: "'stock-GOOG' in topics || 'industry-tech' in topics",
+		},
+		{
+			in:   `"a" in topics&&("b" in topics||'c' in topics)`,
+			want: And(InTopic("a"), Or(InTopic("b"), InTopic("c"))),
+			norm: "'a' in topics && ('b' in topics || 'c' in topics)",
+		},
+		{
+			in:   "'a' in topics || 'b' in topics && !('c' in topics)",
+			want: Or(InTopic("a"), And(InTopic("b"), Not(InTopic("c")))),
+			norm: "'a' in topics || 'b' in topics && !('c' in topics)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Parse() = %#v, want %#v", got, tt.want)
+			}
+			norm, err := Normalize(tt.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if norm != tt.norm {
+				t.Fatalf("Normalize() = %q, want %q", norm, tt.norm)
+			}
+			// The normalized form must parse back to the same tree.
+			again, err := Parse(norm)
+			if err != nil || !reflect.DeepEqual(again, tt.want) {
+				t.Fatalf("normalized form does not round trip: %#v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"'a' in topics &&",
+		"('a' in topics",
+		"'a in topics",
+		"'a' in",
+		"a in topics",
+		"'a' in topics 'b' in topics",
+	} {
+		_, err := Parse(in)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q): expected *SyntaxError, got %v", in, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	six := Or(InTopic("a"), InTopic("b"), InTopic("c"), InTopic("d"), InTopic("e"), InTopic("f"))
+	if err := Validate(six); err == nil || !strings.Contains(err.Error(), "limit of 5") {
+		t.Fatalf("expected topic limit error, got %v", err)
+	}
+	if err := Validate(InTopic("foo bar")); err == nil {
+		t.Fatal("expected invalid topic name error, got nil")
+	}
+	if err := Validate(And()); err == nil {
+		t.Fatal("expected empty operand error, got nil")
+	}
+	if err := Validate(nil); err == nil {
+		t.Fatal("expected nil condition error, got nil")
+	}
+	if got := Topics(six); len(got) != 6 {
+		t.Fatalf("expected 6 topics, got %v", got)
+	}
+}
@@ -0,0 +1,46 @@
+package condition
+
+import "strings"
+
+// Evaluate reports whether a device subscribed to topics would receive a
+// message targeted at expr. Topic names may carry the "/topics/" prefix.
+func Evaluate(expr Expr, topics []string) bool {
+	set := make(map[string]bool, len(topics))
+	for _, t := range topics {
+		set[strings.TrimPrefix(t, "/topics/")] = true
+	}
+	return eval(expr, set)
+}
+
+// Match parses cond and evaluates it against topics.
+func Match(cond string, topics []string) (bool, error) {
+	expr, err := Parse(cond)
+	if err != nil {
+		return false, err
+	}
+	return Evaluate(expr, topics), nil
+}
+
+func eval(expr Expr, topics map[string]bool) bool {
+	switch e := expr.(type) {
+	case TopicExpr:
+		return topics[e.Name]
+	case NotExpr:
+		return !eval(e.X, topics)
+	case AndExpr:
+		for _, x := range e.X {
+			if !eval(x, topics) {
+				return false
+			}
+		}
+		return true
+	case OrExpr:
+		for _, x := range e.X {
+			if eval(x, topics) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package condition
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	const cond = "'stock-GOOG' in topics && ('industry-tech' in topics || !('beta' in topics))"
+	tests := []struct {
+		topics []string
+		want   bool
+	}{
+		{topics: []string{"stock-GOOG"}, want: true},
+		{topics: []string{"/topics/stock-GOOG", "beta"}, want: false},
+		{topics: []string{"stock-GOOG", "beta", "industry-tech"}, want: true},
+		{topics: []string{"industry-tech"}, want: false},
+		{topics: nil, want: false},
+	}
+	for _, tt := range tests {
+		got, err := Match(cond, tt.topics)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Match(%v) = %v, want %v", tt.topics, got, tt.want)
+		}
+	}
+
+	if _, err := Match("'a' in", nil); err == nil {
+		t.Fatal("expected syntax error, got nil")
+	}
+}
@@ -0,0 +1,137 @@
+package condition
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError reports a malformed condition string.
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("condition syntax error at offset %d: %s", e.Offset, e.Msg)
+}
+
+// Parse turns a condition string such as
+// "'a' in topics && ('b' in topics || !('c' in topics))" into an expression.
+// Topic names may be quoted with single or double quotes; && binds tighter
+// than ||. Parse checks syntax only; use Validate for FCM's limits.
+func Parse(s string) (Expr, error) {
+	p := &parser{s: s}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return expr, nil
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *parser) consume(tok string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.s[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (Expr, error) {
+	x, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	operands := []Expr{x}
+	for p.consume("||") {
+		y, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, y)
+	}
+	if len(operands) == 1 {
+		return x, nil
+	}
+	return OrExpr{X: operands}, nil
+}
+
+func (p *parser) and() (Expr, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	operands := []Expr{x}
+	for p.consume("&&") {
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, y)
+	}
+	if len(operands) == 1 {
+		return x, nil
+	}
+	return AndExpr{X: operands}, nil
+}
+
+func (p *parser) unary() (Expr, error) {
+	if p.consume("!") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return NotExpr{X: x}, nil
+	}
+	if p.consume("(") {
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("missing ')'")
+		}
+		return x, nil
+	}
+	return p.topic()
+}
+
+func (p *parser) topic() (Expr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("unexpected end of condition")
+	}
+	quote := p.s[p.pos]
+	if quote != '\'' && quote != '"' {
+		return nil, p.errorf("expected quoted topic name")
+	}
+	end := strings.IndexByte(p.s[p.pos+1:], quote)
+	if end < 0 {
+		return nil, p.errorf("unterminated topic name")
+	}
+	name := p.s[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	if !p.consume("in") || !p.consume("topics") {
+		return nil, p.errorf(`expected "in topics"`)
+	}
+	return InTopic(name), nil
+}
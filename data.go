@@ -0,0 +1,215 @@
+package fcm
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// EncodeData converts a struct into a message data payload, one entry per
+// exported field. The key is taken from the `fcm:"name"` struct tag, or the
+// field name when there is none; `fcm:"-"` skips a field and the omitempty
+// option drops zero values:
+//
+//	type Order struct {
+//		ID      int64     `fcm:"order_id"`
+//		Paid    bool      `fcm:"paid"`
+//		Shipped time.Time `fcm:"shipped_at,omitempty"`
+//		Items   []Item    `fcm:"items"`
+//	}
+//
+// Strings are used as is; booleans and numbers are formatted with strconv;
+// types implementing encoding.TextMarshaler, such as time.Time (RFC 3339),
+// use their text form; slices, arrays, maps and nested structs are encoded as
+// JSON strings. Nil pointers are omitted.
+func EncodeData(v any) (map[string]string, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, errors.New("cannot encode nil value as data")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot encode %s as data, want a struct", rv.Type())
+	}
+	if !rv.CanAddr() {
+		// Make the fields addressable so pointer-receiver MarshalText methods
+		// are found, as DecodeData finds pointer-receiver UnmarshalText ones.
+		addressable := reflect.New(rv.Type()).Elem()
+		addressable.Set(rv)
+		rv = addressable
+	}
+
+	data := map[string]string{}
+	for _, f := range dataFields(rv.Type()) {
+		fv := rv.FieldByIndex(f.index)
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		if fv.Kind() == reflect.Pointer && fv.IsNil() {
+			continue
+		}
+		s, err := encodeDataValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode field %s: %w", f.name, err)
+		}
+		data[f.key] = s
+	}
+	return data, nil
+}
+
+// DecodeData is the inverse of EncodeData: it parses the entries of data into
+// the fields of the struct v points to. Keys without a matching field are
+// ignored and fields without a matching key are left untouched.
+func DecodeData(data map[string]string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode data into %T, want a non-nil pointer to a struct", v)
+	}
+	rv = rv.Elem()
+
+	for _, f := range dataFields(rv.Type()) {
+		s, ok := data[f.key]
+		if !ok {
+			continue
+		}
+		if err := decodeDataValue(s, rv.FieldByIndex(f.index)); err != nil {
+			return fmt.Errorf("cannot decode key %q into field %s: %w", f.key, f.name, err)
+		}
+	}
+	return nil
+}
+
+// DataFrom is a MessageOption that merges EncodeData(v) into the message
+// data payload.
+func DataFrom(v any) MessageOption {
+	return func(m *messaging.Message, _ time.Time) error {
+		data, err := EncodeData(v)
+		if err != nil {
+			return err
+		}
+		if m.Data == nil {
+			m.Data = make(map[string]string, len(data))
+		}
+		for k, s := range data {
+			m.Data[k] = s
+		}
+		return nil
+	}
+}
+
+type dataField struct {
+	name      string
+	key       string
+	index     []int
+	omitEmpty bool
+}
+
+func dataFields(t reflect.Type) []dataField {
+	var fields []dataField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("fcm")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, dataField{
+			name:      sf.Name,
+			key:       name,
+			index:     sf.Index,
+			omitEmpty: slices.Contains(strings.Split(opts, ","), "omitempty"),
+		})
+	}
+	return fields
+}
+
+func encodeDataValue(v reflect.Value) (string, error) {
+	if v.Kind() != reflect.Pointer && v.CanAddr() && v.Addr().Type().Implements(textMarshalerType) {
+		v = v.Addr()
+	}
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		return encodeDataValue(v.Elem())
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		b, err := json.Marshal(v.Interface())
+		return string(b), err
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func decodeDataValue(s string, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeDataValue(s, v.Elem())
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
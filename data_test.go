@@ -0,0 +1,131 @@
+package fcm
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+type dataItem struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+type dataOrder struct {
+	ID       int64             `fcm:"order_id"`
+	Paid     bool              `fcm:"paid"`
+	Total    float64           `fcm:"total"`
+	Status   string            `fcm:"status"`
+	Shipped  time.Time         `fcm:"shipped_at,omitempty"`
+	Items    []dataItem        `fcm:"items"`
+	Labels   map[string]string `fcm:"labels,omitempty"`
+	Coupon   *string           `fcm:"coupon"`
+	Internal string            `fcm:"-"`
+	Retries  uint8
+}
+
+func TestEncodeDecodeData(t *testing.T) {
+	in := dataOrder{
+		ID:       42,
+		Paid:     true,
+		Total:    12.5,
+		Status:   "shipped",
+		Shipped:  time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC),
+		Items:    []dataItem{{SKU: "A1", Qty: 2}},
+		Internal: "secret",
+		Retries:  3,
+	}
+	data, err := EncodeData(&in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"order_id":   "42",
+		"paid":       "true",
+		"total":      "12.5",
+		"status":     "shipped",
+		"shipped_at": "2024-05-01T10:30:00Z",
+		"items":      `[{"sku":"A1","qty":2}]`,
+		"Retries":    "3",
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Fatalf("EncodeData() = %v, want %v", data, want)
+	}
+
+	var out dataOrder
+	if err := DecodeData(data, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	in.Internal = ""
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("DecodeData() = %+v, want %+v", out, in)
+	}
+
+	msg := &messaging.Message{Token: "test", Data: map[string]string{"kind": "order"}}
+	if err := ApplyMessageOptions(msg, DataFrom(in)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.Data["kind"] != "order" || msg.Data["order_id"] != "42" {
+		t.Fatalf("unexpected merged data: %v", msg.Data)
+	}
+}
+
+// dataLevel implements encoding.TextMarshaler with a pointer receiver only.
+type dataLevel struct {
+	n int
+}
+
+func (l *dataLevel) MarshalText() ([]byte, error) {
+	return []byte("level-" + strconv.Itoa(l.n)), nil
+}
+
+func (l *dataLevel) UnmarshalText(b []byte) error {
+	n, err := strconv.Atoi(strings.TrimPrefix(string(b), "level-"))
+	l.n = n
+	return err
+}
+
+type dataProfile struct {
+	Level dataLevel `fcm:"level"`
+	Note  string    `fcm:"note,omitempty,string"`
+}
+
+func TestEncodeDecodeDataPointerTextMarshaler(t *testing.T) {
+	in := dataProfile{Level: dataLevel{n: 3}}
+	for _, v := range []any{in, &in} {
+		data, err := EncodeData(v)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := map[string]string{"level": "level-3"}; !reflect.DeepEqual(data, want) {
+			t.Fatalf("EncodeData(%T) = %v, want %v", v, data, want)
+		}
+		var out dataProfile
+		if err := DecodeData(data, &out); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out != in {
+			t.Fatalf("DecodeData() = %+v, want %+v", out, in)
+		}
+	}
+}
+
+func TestDataErrors(t *testing.T) {
+	if _, err := EncodeData(42); err == nil {
+		t.Fatal("expected error encoding a non-struct, got nil")
+	}
+	if _, err := EncodeData(struct{ F func() }{}); err == nil {
+		t.Fatal("expected error encoding a func field, got nil")
+	}
+	var out dataOrder
+	if err := DecodeData(map[string]string{"paid": "maybe"}, &out); err == nil {
+		t.Fatal("expected error decoding an invalid bool, got nil")
+	}
+	if err := DecodeData(nil, out); err == nil {
+		t.Fatal("expected error decoding into a non-pointer, got nil")
+	}
+}
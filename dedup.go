@@ -0,0 +1,109 @@
+package fcm
+
+import (
+	"errors"
+	"strings"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// TokenDedup maps a list of registration tokens to its distinct entries.
+type TokenDedup struct {
+	// Unique holds the distinct tokens, trimmed of surrounding whitespace, in
+	// order of first appearance.
+	Unique []string
+	// Index maps every input position to the position of its token in Unique,
+	// or to -1 when the token is empty once trimmed.
+	Index []int
+}
+
+// errEmptyToken is reported for the entries DedupTokens leaves out of Unique.
+var errEmptyToken = errors.New("token must not be empty")
+
+// DedupTokens trims the surrounding whitespace of tokens and collapses the
+// duplicates. Tokens that are empty once trimmed are left out of Unique, so
+// they fail on their own instead of failing the whole call.
+func DedupTokens(tokens []string) TokenDedup {
+	d := TokenDedup{Index: make([]int, len(tokens))}
+	seen := make(map[string]int, len(tokens))
+	for i, token := range tokens {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			d.Index[i] = -1
+			continue
+		}
+		u, ok := seen[token]
+		if !ok {
+			u = len(d.Unique)
+			seen[token] = u
+			d.Unique = append(d.Unique, token)
+		}
+		d.Index[i] = u
+	}
+	return d
+}
+
+// Collapsed returns the input positions whose token repeats an earlier one.
+func (d TokenDedup) Collapsed() []int {
+	var collapsed []int
+	first := make([]bool, len(d.Unique))
+	for i, u := range d.Index {
+		if u < 0 {
+			continue
+		}
+		if first[u] {
+			collapsed = append(collapsed, i)
+		}
+		first[u] = true
+	}
+	return collapsed
+}
+
+// expandBatch maps the responses for d.Unique back to every input position.
+// Collapsed entries share the *messaging.SendResponse of the first
+// occurrence; empty entries fail.
+func (d TokenDedup) expandBatch(resp *messaging.BatchResponse) *messaging.BatchResponse {
+	if len(d.Unique) > 0 && (resp == nil || len(resp.Responses) != len(d.Unique)) {
+		return resp
+	}
+	expanded := &messaging.BatchResponse{Responses: make([]*messaging.SendResponse, len(d.Index))}
+	for i, u := range d.Index {
+		r := &messaging.SendResponse{Error: errEmptyToken}
+		if u >= 0 {
+			r = resp.Responses[u]
+		}
+		expanded.Responses[i] = r
+		if r != nil && r.Success {
+			expanded.SuccessCount++
+		} else {
+			expanded.FailureCount++
+		}
+	}
+	return expanded
+}
+
+// expandTopic maps a topic management response for d.Unique back to every
+// input position: an error of a token is reported at each of its positions,
+// and empty entries fail with INVALID_ARGUMENT.
+func (d TokenDedup) expandTopic(resp *messaging.TopicManagementResponse) *messaging.TopicManagementResponse {
+	if resp == nil && len(d.Unique) > 0 {
+		return nil
+	}
+	byUnique := map[int]*messaging.ErrorInfo{}
+	if resp != nil {
+		for _, e := range resp.Errors {
+			byUnique[e.Index] = e
+		}
+	}
+	expanded := &messaging.TopicManagementResponse{}
+	for i, u := range d.Index {
+		if u < 0 {
+			expanded.Errors = append(expanded.Errors, &messaging.ErrorInfo{Index: i, Reason: "INVALID_ARGUMENT"})
+		} else if e, ok := byUnique[u]; ok {
+			expanded.Errors = append(expanded.Errors, &messaging.ErrorInfo{Index: i, Reason: e.Reason})
+		}
+	}
+	expanded.FailureCount = len(expanded.Errors)
+	expanded.SuccessCount = len(d.Index) - expanded.FailureCount
+	return expanded
+}
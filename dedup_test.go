@@ -0,0 +1,120 @@
+package fcm_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"firebase.google.com/go/v4/messaging"
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+)
+
+func TestDedupTokens(t *testing.T) {
+	d := fcm.DedupTokens([]string{"a", " b", "a ", "c", "b"})
+	if !reflect.DeepEqual(d.Unique, []string{"a", "b", "c"}) {
+		t.Fatalf("Unique = %v", d.Unique)
+	}
+	if !reflect.DeepEqual(d.Index, []int{0, 1, 0, 2, 1}) {
+		t.Fatalf("Index = %v", d.Index)
+	}
+	if !reflect.DeepEqual(d.Collapsed(), []int{2, 4}) {
+		t.Fatalf("Collapsed() = %v", d.Collapsed())
+	}
+}
+
+func TestClientTokenDedup(t *testing.T) {
+	ctx := context.Background()
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+	srv.FailToken("gone", "UNREGISTERED")
+
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+		fcm.WithTokenDedup(true),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tokens := []string{"a", " a", "gone", "b\n", "gone"}
+	resp, err := client.SendMulticast(ctx, &messaging.MulticastMessage{Tokens: tokens})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(srv.Messages()) != 2 {
+		t.Fatalf("expected 2 messages to be sent, got %d", len(srv.Messages()))
+	}
+	if len(resp.Responses) != 5 || resp.SuccessCount != 3 || resp.FailureCount != 2 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.Responses[0] != resp.Responses[1] || resp.Responses[2] != resp.Responses[4] {
+		t.Fatal("expected collapsed entries to share the response of the first occurrence")
+	}
+
+	topicResp, err := client.SubscribeTopic(ctx, tokens, "news")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if topicResp.SuccessCount != 3 || topicResp.FailureCount != 2 ||
+		topicResp.Errors[0].Index != 2 || topicResp.Errors[1].Index != 4 {
+		t.Fatalf("unexpected topic response: %+v", topicResp)
+	}
+	if got := srv.TopicMembers("news"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("TopicMembers() = %v", got)
+	}
+
+	topicResp, err = client.UnsubscribeTopic(ctx, []string{"b", "b "}, "news")
+	if err != nil || topicResp.SuccessCount != 2 {
+		t.Fatalf("unexpected result: %+v %v", topicResp, err)
+	}
+	if got := srv.TopicMembers("news"); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Fatalf("TopicMembers() = %v", got)
+	}
+}
+
+func TestClientTokenDedupEmptyTokens(t *testing.T) {
+	ctx := context.Background()
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+		fcm.WithTokenDedup(true),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	d := fcm.DedupTokens([]string{"a", "  ", "a"})
+	if !reflect.DeepEqual(d.Unique, []string{"a"}) || !reflect.DeepEqual(d.Index, []int{0, -1, 0}) {
+		t.Fatalf("DedupTokens() = %+v", d)
+	}
+
+	resp, err := client.SendMulticast(ctx, &messaging.MulticastMessage{Tokens: []string{"a", " ", "b"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.SuccessCount != 2 || resp.FailureCount != 1 || resp.Responses[1].Error == nil {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	resp, err = client.SendMulticast(ctx, &messaging.MulticastMessage{Tokens: []string{"", "\t"}})
+	if err != nil || resp.FailureCount != 2 {
+		t.Fatalf("unexpected result: %+v %v", resp, err)
+	}
+
+	topicResp, err := client.SubscribeTopic(ctx, []string{"a", " "}, "news")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if topicResp.SuccessCount != 1 || topicResp.FailureCount != 1 || topicResp.Errors[0].Index != 1 {
+		t.Fatalf("unexpected topic response: %+v", topicResp)
+	}
+	if got := srv.TopicMembers("news"); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Fatalf("TopicMembers() = %v", got)
+	}
+}
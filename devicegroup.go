@@ -0,0 +1,157 @@
+package fcm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// defaultDeviceGroupEndpoint is the FCM device group API, which
+// WithDeviceGroupEndpoint replaces.
+const defaultDeviceGroupEndpoint = "https://fcm.googleapis.com"
+
+// MaxDeviceGroupSize is the largest number of registration tokens a device
+// group holds.
+const MaxDeviceGroupSize = 20
+
+// Errors matched by the *DeviceGroupError the device group methods return.
+var (
+	ErrDeviceGroupExists      = errors.New("notification_key already exists")
+	ErrDeviceGroupNotFound    = errors.New("notification_key not found")
+	ErrNoValidRegistrationIDs = errors.New("no valid registration ids")
+)
+
+// DeviceGroupError is returned when the FCM device group API rejects a
+// request. It matches ErrDeviceGroupExists, ErrDeviceGroupNotFound or
+// ErrNoValidRegistrationIDs with errors.Is when the reason is one of theirs.
+type DeviceGroupError struct {
+	StatusCode int
+	Reason     string
+}
+
+func (e *DeviceGroupError) Error() string {
+	return fmt.Sprintf("device group request failed with status %d: %s", e.StatusCode, e.Reason)
+}
+
+// Unwrap returns the sentinel error matching Reason, if any.
+func (e *DeviceGroupError) Unwrap() error {
+	for _, err := range []error{ErrDeviceGroupExists, ErrDeviceGroupNotFound, ErrNoValidRegistrationIDs} {
+		if strings.Contains(e.Reason, err.Error()) {
+			return err
+		}
+	}
+	return nil
+}
+
+type deviceGroupRequest struct {
+	Operation       string   `json:"operation"`
+	NotificationKey string   `json:"notification_key,omitempty"`
+	KeyName         string   `json:"notification_key_name"`
+	RegistrationIDs []string `json:"registration_ids"`
+}
+
+type deviceGroupResponse struct {
+	NotificationKey string `json:"notification_key"`
+}
+
+// CreateDeviceGroup creates the device group name with tokens as its members
+// and returns its notification_key.
+func (c *Client) CreateDeviceGroup(ctx context.Context, name string, tokens []string) (string, error) {
+	return c.deviceGroupOperation(ctx, deviceGroupRequest{
+		Operation:       "create",
+		KeyName:         name,
+		RegistrationIDs: tokens,
+	})
+}
+
+// AddToDeviceGroup adds tokens to the device group name identified by key and
+// returns its notification_key.
+func (c *Client) AddToDeviceGroup(ctx context.Context, name, key string, tokens []string) (string, error) {
+	return c.deviceGroupOperation(ctx, deviceGroupRequest{
+		Operation:       "add",
+		NotificationKey: key,
+		KeyName:         name,
+		RegistrationIDs: tokens,
+	})
+}
+
+// RemoveFromDeviceGroup removes tokens from the device group name identified
+// by key and returns its notification_key. FCM deletes the group once its
+// last token is removed.
+func (c *Client) RemoveFromDeviceGroup(ctx context.Context, name, key string, tokens []string) (string, error) {
+	return c.deviceGroupOperation(ctx, deviceGroupRequest{
+		Operation:       "remove",
+		NotificationKey: key,
+		KeyName:         name,
+		RegistrationIDs: tokens,
+	})
+}
+
+// DeviceGroupKey looks up the notification_key of the device group name.
+func (c *Client) DeviceGroupKey(ctx context.Context, name string) (string, error) {
+	if name == "" {
+		return "", errors.New("device group name must not be empty")
+	}
+	var resp deviceGroupResponse
+	path := "/fcm/notification?notification_key_name=" + url.QueryEscape(name)
+	if err := c.deviceGroupRequest(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.NotificationKey, nil
+}
+
+// SendToDeviceGroup sends message to every device of the group identified by
+// key, by targeting the key through the Token field.
+func (c *Client) SendToDeviceGroup(
+	ctx context.Context,
+	key string,
+	message *messaging.Message,
+) (*messaging.BatchResponse, error) {
+	if key == "" {
+		return nil, errors.New("notification key must not be empty")
+	}
+	if message == nil {
+		return nil, errors.New("message must not be nil")
+	}
+	m := *message
+	m.Token, m.Topic, m.Condition = key, "", ""
+	return c.Send(ctx, &m)
+}
+
+func (c *Client) deviceGroupOperation(ctx context.Context, req deviceGroupRequest) (string, error) {
+	switch {
+	case req.KeyName == "":
+		return "", errors.New("device group name must not be empty")
+	case req.Operation != "create" && req.NotificationKey == "":
+		return "", errors.New("notification key must not be empty")
+	case len(req.RegistrationIDs) == 0 || len(req.RegistrationIDs) > MaxDeviceGroupSize:
+		return "", fmt.Errorf("device group operations take 1 to %d tokens, got %d", MaxDeviceGroupSize, len(req.RegistrationIDs))
+	}
+	var resp deviceGroupResponse
+	if err := c.deviceGroupRequest(ctx, http.MethodPost, "/fcm/notification", req, &resp); err != nil {
+		return "", err
+	}
+	return resp.NotificationKey, nil
+}
+
+// deviceGroupRequest sends an authorized request to the device group API,
+// which identifies the project through the sender ID in the project_id header.
+func (c *Client) deviceGroupRequest(ctx context.Context, method, path string, body, out any) error {
+	if c.senderID == "" {
+		return errors.New("device groups require WithSenderID")
+	}
+	endpoint := c.groupEndpoint
+	if endpoint == "" {
+		endpoint = defaultDeviceGroupEndpoint
+	}
+	header := http.Header{"project_id": {c.senderID}}
+	return c.jsonRequest(ctx, endpoint, method, path, header, body, out,
+		func(status int, reason string) error {
+			return &DeviceGroupError{StatusCode: status, Reason: reason}
+		})
+}
@@ -0,0 +1,120 @@
+package fcm_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"firebase.google.com/go/v4/messaging"
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+	"golang.org/x/oauth2"
+)
+
+func TestDeviceGroups(t *testing.T) {
+	ctx := context.Background()
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+	srv.FailToken("dead", "UNREGISTERED")
+
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("test"),
+		fcm.WithSenderID("123456"),
+		fcm.WithHTTPClient(srv.Client()),
+		fcm.WithPreflightValidation(true),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	key, err := client.CreateDeviceGroup(ctx, "alice", []string{"phone", "tablet"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.CreateDeviceGroup(ctx, "alice", []string{"laptop"}); !errors.Is(err, fcm.ErrDeviceGroupExists) {
+		t.Fatalf("expected ErrDeviceGroupExists, got %v", err)
+	}
+	if got, err := client.DeviceGroupKey(ctx, "alice"); err != nil || got != key {
+		t.Fatalf("DeviceGroupKey() = %q, %v, want %q", got, err, key)
+	}
+
+	if _, err := client.AddToDeviceGroup(ctx, "alice", key, []string{"laptop"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.RemoveFromDeviceGroup(ctx, "alice", key, []string{"tablet"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, members, _ := srv.DeviceGroup("alice"); !reflect.DeepEqual(members, []string{"laptop", "phone"}) {
+		t.Fatalf("members = %v", members)
+	}
+
+	resp, err := client.SendToDeviceGroup(ctx, key, &messaging.Message{
+		Topic:        "ignored",
+		Notification: &messaging.Notification{Title: "hello"},
+	})
+	if err != nil || resp.SuccessCount != 1 {
+		t.Fatalf("unexpected result: %+v %v", resp, err)
+	}
+	if len(srv.DeliveredTo("laptop")) != 1 || len(srv.DeliveredTo("phone")) != 1 || len(srv.DeliveredTo("tablet")) != 0 {
+		t.Fatal("expected the message to reach the current members only")
+	}
+
+	var groupErr *fcm.DeviceGroupError
+	_, err = client.AddToDeviceGroup(ctx, "alice", "wrong-key", []string{"tv"})
+	if !errors.As(err, &groupErr) || !errors.Is(err, fcm.ErrDeviceGroupNotFound) {
+		t.Fatalf("expected a not found DeviceGroupError, got %v", err)
+	}
+	if _, err := client.CreateDeviceGroup(ctx, "bob", []string{"dead"}); !errors.Is(err, fcm.ErrNoValidRegistrationIDs) {
+		t.Fatalf("expected ErrNoValidRegistrationIDs, got %v", err)
+	}
+	if _, err := client.DeviceGroupKey(ctx, "bob"); !errors.Is(err, fcm.ErrDeviceGroupNotFound) {
+		t.Fatalf("expected ErrDeviceGroupNotFound, got %v", err)
+	}
+
+	if _, err := client.RemoveFromDeviceGroup(ctx, "alice", key, []string{"laptop", "phone"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, ok := srv.DeviceGroup("alice"); ok {
+		t.Fatal("expected the empty group to be deleted")
+	}
+}
+
+func TestDeviceGroupsRequireSenderID(t *testing.T) {
+	ctx := context.Background()
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.CreateDeviceGroup(ctx, "alice", []string{"phone"}); err == nil {
+		t.Fatal("expected error without a sender ID, got nil")
+	}
+	if _, _, ok := srv.DeviceGroup("alice"); ok {
+		t.Fatal("expected no group to be created")
+	}
+
+	client, err = fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("test"),
+		fcm.WithSenderID("123456"),
+		fcm.WithDeviceGroupEndpoint(srv.URL+"/"),
+		fcm.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "test-token"})),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.CreateDeviceGroup(ctx, "alice", []string{"phone"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, ok := srv.DeviceGroup("alice"); !ok {
+		t.Fatal("expected the group to be created through the endpoint")
+	}
+}
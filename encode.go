@@ -0,0 +1,42 @@
+package fcm
+
+import (
+	"encoding/json"
+	"errors"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// sendRequest mirrors the envelope the Firebase SDK posts to the
+// projects.messages:send endpoint.
+type sendRequest struct {
+	ValidateOnly bool               `json:"validate_only,omitempty"`
+	Message      *messaging.Message `json:"message,omitempty"`
+}
+
+// EncodeRequest returns the JSON body Client.Send (or Client.SendDryRun when
+// dryRun is true) puts on the wire for the given message, including the
+// {"message": ..., "validate_only": ...} envelope. It is intended for
+// golden-file tests and for inspecting payloads without contacting FCM.
+func EncodeRequest(message *messaging.Message, dryRun bool) ([]byte, error) {
+	if message == nil {
+		return nil, errors.New("message must not be nil")
+	}
+	return json.Marshal(&sendRequest{
+		ValidateOnly: dryRun,
+		Message:      message,
+	})
+}
+
+// DecodeRequest parses a body produced by EncodeRequest (or captured from the
+// wire) back into a message, reporting whether it was a dry run.
+func DecodeRequest(data []byte) (*messaging.Message, bool, error) {
+	var req sendRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, false, err
+	}
+	if req.Message == nil {
+		return nil, false, errors.New("request does not contain a message")
+	}
+	return req.Message, req.ValidateOnly, nil
+}
@@ -0,0 +1,102 @@
+package fcm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+func TestEncodeRequestMatchesWire(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		body []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		body = b
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"name": "q1w2e3r4"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(
+		context.Background(),
+		WithEndpoint(server.URL),
+		WithProjectID("test"),
+		WithTokenSource(&MockTokenSource{AccessToken: "test-token"}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ttl := 90 * time.Second
+	msg := &messaging.Message{
+		Topic:        "/topics/news",
+		Data:         map[string]string{"foo": "bar"},
+		Notification: &messaging.Notification{Title: "hello", Body: "world"},
+		Android:      &messaging.AndroidConfig{Priority: "high", TTL: &ttl},
+	}
+
+	for _, dryRun := range []bool{false, true} {
+		var sendErr error
+		if dryRun {
+			_, sendErr = client.SendDryRun(context.Background(), msg)
+		} else {
+			_, sendErr = client.Send(context.Background(), msg)
+		}
+		if sendErr != nil {
+			t.Fatalf("unexpected error: %v", sendErr)
+		}
+
+		want, err := EncodeRequest(msg, dryRun)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		mu.Lock()
+		got := body
+		mu.Unlock()
+		if !bytes.Equal(got, want) {
+			t.Fatalf("dryRun=%v: wire body mismatch\nwire:    %s\nencoded: %s", dryRun, got, want)
+		}
+	}
+}
+
+func TestDecodeRequestRoundTrip(t *testing.T) {
+	msg := &messaging.Message{
+		Token: "device-token",
+		Data:  map[string]string{"foo": "bar"},
+	}
+	b, err := EncodeRequest(msg, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, dryRun, err := DecodeRequest(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !dryRun {
+		t.Fatal("expected dry run to be decoded")
+	}
+	if got.Token != msg.Token || got.Data["foo"] != "bar" {
+		t.Fatalf("unexpected decoded message: %+v", got)
+	}
+}
+
+func TestDecodeRequestMissingMessage(t *testing.T) {
+	if _, _, err := DecodeRequest([]byte(`{"validate_only": true}`)); err == nil {
+		t.Fatal("expected error for request without message, got nil")
+	}
+	if _, err := EncodeRequest(nil, false); err == nil {
+		t.Fatal("expected error for nil message, got nil")
+	}
+}
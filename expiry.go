@@ -0,0 +1,169 @@
+package fcm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// Platform limits for expiry and collapse settings.
+const (
+	// MaxTTL is the longest time FCM stores a message for an offline device.
+	MaxTTL = 28 * 24 * time.Hour
+	// maxAPNSCollapseIDLength is the byte limit of the apns-collapse-id header.
+	maxAPNSCollapseIDLength = 64
+	// maxWebpushTopicLength is the character limit of the Webpush Topic header.
+	maxWebpushTopicLength = 32
+)
+
+// webpushTopicPattern matches the URL-safe base64 alphabet RFC 8030 requires
+// for the Topic header.
+var webpushTopicPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// MessageOption sets a cross-platform property on a message. now is the time
+// the option is applied at, used to turn durations into absolute expiries.
+type MessageOption func(m *messaging.Message, now time.Time) error
+
+// ApplyMessageOptions applies opts to msg using the system clock. Use
+// Client.ApplyMessageOptions or ApplyMessageOptionsAt to control the time.
+func ApplyMessageOptions(msg *messaging.Message, opts ...MessageOption) error {
+	return ApplyMessageOptionsAt(msg, realClock{}.Now(), opts...)
+}
+
+// ApplyMessageOptions applies opts to msg using the Client's Clock.
+func (c *Client) ApplyMessageOptions(msg *messaging.Message, opts ...MessageOption) error {
+	return ApplyMessageOptionsAt(msg, c.clock.Now(), opts...)
+}
+
+// ApplyMessageOptionsAt applies opts to msg as if the time were now.
+func ApplyMessageOptionsAt(msg *messaging.Message, now time.Time, opts ...MessageOption) error {
+	if msg == nil {
+		return errors.New("message must not be nil")
+	}
+	for _, o := range opts {
+		if err := o(msg, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpiresIn keeps the message for an offline device for at most d, by setting
+// the Android TTL, the apns-expiration header and the Webpush TTL header
+// together. d must be between 0 and MaxTTL; 0 means deliver immediately or
+// drop.
+func ExpiresIn(d time.Duration) MessageOption {
+	return func(m *messaging.Message, now time.Time) error {
+		if d < 0 || d > MaxTTL {
+			return fmt.Errorf("expiry %s must be between 0 and %s", d, MaxTTL)
+		}
+		ttl := d
+		ensureAndroid(m).TTL = &ttl
+
+		expiration := "0"
+		if d > 0 {
+			expiration = strconv.FormatInt(now.Add(d).Unix(), 10)
+		}
+		setHeader(&ensureAPNS(m).Headers, "apns-expiration", expiration)
+		setHeader(&ensureWebpush(m).Headers, "TTL", strconv.FormatInt(int64(d/time.Second), 10))
+		return nil
+	}
+}
+
+// ExpiresAt is ExpiresIn for the time remaining until t.
+func ExpiresAt(t time.Time) MessageOption {
+	return func(m *messaging.Message, now time.Time) error {
+		d := t.Sub(now)
+		if d < 0 {
+			return fmt.Errorf("expiry time %s is in the past", t.Format(time.RFC3339))
+		}
+		return ExpiresIn(d)(m, now)
+	}
+}
+
+// CollapseKey makes newer messages with the same key replace older ones that
+// have not been delivered yet, by setting the Android collapse key, the
+// apns-collapse-id header and the Webpush Topic header together. key must
+// satisfy the strictest platform: at most 32 characters from [A-Za-z0-9_-].
+func CollapseKey(key string) MessageOption {
+	return func(m *messaging.Message, _ time.Time) error {
+		if err := validateWebpushTopic(key); err != nil {
+			return fmt.Errorf("invalid collapse key: %w", err)
+		}
+		ensureAndroid(m).CollapseKey = key
+		setHeader(&ensureAPNS(m).Headers, "apns-collapse-id", key)
+		setHeader(&ensureWebpush(m).Headers, "Topic", key)
+		return nil
+	}
+}
+
+// validateExpiry checks the expiry and collapse settings of a message
+// against each platform's limits.
+func validateExpiry(m *messaging.Message) error {
+	var errs []error
+	if m.Android != nil && m.Android.TTL != nil && (*m.Android.TTL < 0 || *m.Android.TTL > MaxTTL) {
+		errs = append(errs, fmt.Errorf("android: ttl %s must be between 0 and %s", *m.Android.TTL, MaxTTL))
+	}
+	if m.APNS != nil {
+		if id := m.APNS.Headers["apns-collapse-id"]; len(id) > maxAPNSCollapseIDLength {
+			errs = append(errs, fmt.Errorf("apns: apns-collapse-id is %d bytes, exceeding %d", len(id), maxAPNSCollapseIDLength))
+		}
+		if exp, ok := m.APNS.Headers["apns-expiration"]; ok {
+			if _, err := strconv.ParseInt(exp, 10, 64); err != nil {
+				errs = append(errs, fmt.Errorf("apns: apns-expiration must be a UNIX epoch in seconds, got %q", exp))
+			}
+		}
+	}
+	if m.Webpush != nil {
+		if topic, ok := m.Webpush.Headers["Topic"]; ok {
+			if err := validateWebpushTopic(topic); err != nil {
+				errs = append(errs, fmt.Errorf("webpush: %w", err))
+			}
+		}
+		if ttl, ok := m.Webpush.Headers["TTL"]; ok {
+			if n, err := strconv.ParseInt(ttl, 10, 64); err != nil || n < 0 {
+				errs = append(errs, fmt.Errorf("webpush: TTL must be a non-negative number of seconds, got %q", ttl))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateWebpushTopic(topic string) error {
+	if len(topic) > maxWebpushTopicLength || !webpushTopicPattern.MatchString(topic) {
+		return fmt.Errorf("topic %q must be 1 to %d characters from [A-Za-z0-9_-]", topic, maxWebpushTopicLength)
+	}
+	return nil
+}
+
+func ensureAndroid(m *messaging.Message) *messaging.AndroidConfig {
+	if m.Android == nil {
+		m.Android = &messaging.AndroidConfig{}
+	}
+	return m.Android
+}
+
+func ensureAPNS(m *messaging.Message) *messaging.APNSConfig {
+	if m.APNS == nil {
+		m.APNS = &messaging.APNSConfig{}
+	}
+	return m.APNS
+}
+
+func ensureWebpush(m *messaging.Message) *messaging.WebpushConfig {
+	if m.Webpush == nil {
+		m.Webpush = &messaging.WebpushConfig{}
+	}
+	return m.Webpush
+}
+
+func setHeader(headers *map[string]string, key, value string) {
+	if *headers == nil {
+		*headers = map[string]string{}
+	}
+	(*headers)[key] = value
+}
@@ -0,0 +1,87 @@
+package fcm
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+func TestExpiryAndCollapseOptions(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := &Client{clock: fixedClock{now}}
+
+	msg := &messaging.Message{Token: "test"}
+	err := client.ApplyMessageOptions(msg, ExpiresIn(time.Hour), CollapseKey("score_update"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *msg.Android.TTL != time.Hour || msg.Android.CollapseKey != "score_update" {
+		t.Fatalf("unexpected android config: %+v", msg.Android)
+	}
+	wantExp := strconv.FormatInt(now.Add(time.Hour).Unix(), 10)
+	if msg.APNS.Headers["apns-expiration"] != wantExp || msg.APNS.Headers["apns-collapse-id"] != "score_update" {
+		t.Fatalf("unexpected apns headers: %v", msg.APNS.Headers)
+	}
+	if msg.Webpush.Headers["TTL"] != "3600" || msg.Webpush.Headers["Topic"] != "score_update" {
+		t.Fatalf("unexpected webpush headers: %v", msg.Webpush.Headers)
+	}
+	if err := Validate(msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msg = &messaging.Message{Token: "test"}
+	if err := client.ApplyMessageOptions(msg, ExpiresAt(now.Add(90*time.Second))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *msg.Android.TTL != 90*time.Second {
+		t.Fatalf("unexpected ttl: %s", *msg.Android.TTL)
+	}
+	if err := client.ApplyMessageOptions(msg, ExpiresIn(0)); err != nil || msg.APNS.Headers["apns-expiration"] != "0" {
+		t.Fatalf("expected immediate expiry, got %v %v", msg.APNS.Headers, err)
+	}
+}
+
+func TestExpiryAndCollapseLimits(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		opt  MessageOption
+		want string
+	}{
+		{name: "ttl too long", opt: ExpiresIn(29 * 24 * time.Hour), want: "must be between 0 and"},
+		{name: "negative ttl", opt: ExpiresIn(-time.Second), want: "must be between 0 and"},
+		{name: "past expiry", opt: ExpiresAt(now.Add(-time.Minute)), want: "in the past"},
+		{name: "long collapse key", opt: CollapseKey(strings.Repeat("k", 33)), want: "invalid collapse key"},
+		{name: "collapse key charset", opt: CollapseKey("a b"), want: "invalid collapse key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ApplyMessageOptionsAt(&messaging.Message{}, now, tt.opt)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	long := 30 * 24 * time.Hour
+	err := Validate(&messaging.Message{
+		Token:   "test",
+		Android: &messaging.AndroidConfig{TTL: &long},
+		APNS:    &messaging.APNSConfig{Headers: map[string]string{"apns-collapse-id": strings.Repeat("x", 65)}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "android: ttl") || !strings.Contains(err.Error(), "apns-collapse-id") {
+		t.Fatalf("expected ttl and collapse id errors, got %v", err)
+	}
+}
+
+// fixedClock is a Clock frozen at a single instant.
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time                         { return c.now }
+func (c fixedClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (c fixedClock) NewTimer(d time.Duration) Timer         { return realClock{}.NewTimer(d) }
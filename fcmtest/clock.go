@@ -0,0 +1,142 @@
+package fcmtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	fcm "github.com/appleboy/go-fcm"
+)
+
+var _ fcm.Clock = (*Clock)(nil)
+
+// Clock is a fake fcm.Clock whose time only moves when Advance or Set is
+// called. Timers and After channels fire synchronously during Advance once
+// their deadline is reached.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewClock returns a fake clock set to start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the fake current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After waits for the fake time to advance by d and then sends the fake
+// current time on the returned channel.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer creates a timer that fires once the fake time has advanced by d.
+func (c *Clock) NewTimer(d time.Duration) fcm.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	c.scheduleLocked(t, d)
+	return t
+}
+
+// Advance moves the fake time forward by d, firing every timer whose
+// deadline falls within the interval in deadline order.
+func (c *Clock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the fake time to t, firing every timer whose deadline is not
+// after t. Moving the clock backwards fires nothing.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.when.After(t) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.active = false
+		select {
+		case timer.ch <- timer.when:
+		default:
+		}
+	}
+	c.timers = pending
+}
+
+// Timers returns the number of timers that have not fired or been stopped,
+// so tests can wait until the code under test is blocked on the clock.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (c *Clock) scheduleLocked(t *fakeTimer, d time.Duration) {
+	t.when = c.now.Add(d)
+	t.active = true
+	if d <= 0 {
+		t.active = false
+		t.ch <- t.when
+		return
+	}
+	c.timers = append(c.timers, t)
+}
+
+func (c *Clock) removeLocked(t *fakeTimer) bool {
+	if !t.active {
+		return false
+	}
+	t.active = false
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+type fakeTimer struct {
+	clock  *Clock
+	ch     chan time.Time
+	when   time.Time
+	active bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.removeLocked(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	wasActive := t.clock.removeLocked(t)
+	select {
+	case <-t.ch:
+	default:
+	}
+	t.clock.scheduleLocked(t, d)
+	return wasActive
+}
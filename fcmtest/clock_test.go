@@ -0,0 +1,56 @@
+package fcmtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewClock(start)
+
+	after := clock.After(time.Minute)
+	timer := clock.NewTimer(2 * time.Minute)
+	stopped := clock.NewTimer(30 * time.Second)
+	if !stopped.Stop() {
+		t.Fatal("expected Stop to report an active timer")
+	}
+	if clock.Timers() != 2 {
+		t.Fatalf("expected 2 pending timers, got %d", clock.Timers())
+	}
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-after:
+		t.Fatal("After fired before its deadline")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case got := <-after:
+		if !got.Equal(start.Add(time.Minute)) {
+			t.Fatalf("unexpected fire time %v", got)
+		}
+	default:
+		t.Fatal("After did not fire at its deadline")
+	}
+
+	if !timer.Reset(time.Minute) {
+		t.Fatal("expected Reset to report an active timer")
+	}
+	clock.Advance(time.Minute)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("reset timer did not fire")
+	}
+	select {
+	case <-stopped.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+	if !clock.Now().Equal(start.Add(2 * time.Minute)) {
+		t.Fatalf("unexpected now %v", clock.Now())
+	}
+}
@@ -0,0 +1,44 @@
+package fcmtest
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// GoroutineSampler records the highest goroutine count seen while it runs,
+// sampling every millisecond, so benchmarks can report the fan-out of a call.
+type GoroutineSampler struct {
+	peak atomic.Int64
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartGoroutineSampler starts sampling the goroutine count.
+func StartGoroutineSampler() *GoroutineSampler {
+	s := &GoroutineSampler{stop: make(chan struct{}), done: make(chan struct{})}
+	s.peak.Store(int64(runtime.NumGoroutine()))
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if n := int64(runtime.NumGoroutine()); n > s.peak.Load() {
+					s.peak.Store(n)
+				}
+			}
+		}
+	}()
+	return s
+}
+
+// Stop ends the sampling and returns the peak goroutine count.
+func (s *GoroutineSampler) Stop() int64 {
+	close(s.stop)
+	<-s.done
+	return s.peak.Load()
+}
@@ -0,0 +1,121 @@
+package fcmtest_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"firebase.google.com/go/v4/messaging"
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+)
+
+func TestTopicAndConditionRouting(t *testing.T) {
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := client.SubscribeTopic(ctx, []string{"a", "b", "c"}, "stock-GOOG"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.SubscribeTopic(ctx, []string{"c", "d"}, "/topics/industry-tech"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.UnsubscribeTopic(ctx, []string{"b"}, "stock-GOOG"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := srv.Subscriptions("c"); !reflect.DeepEqual(got, []string{"industry-tech", "stock-GOOG"}) {
+		t.Fatalf("unexpected subscriptions for c: %v", got)
+	}
+
+	tests := []struct {
+		name string
+		msg  *messaging.Message
+		want []string
+	}{
+		{
+			name: "token",
+			msg:  &messaging.Message{Token: "z"},
+			want: []string{"z"},
+		},
+		{
+			name: "topic",
+			msg:  &messaging.Message{Topic: "stock-GOOG"},
+			want: []string{"a", "c"},
+		},
+		{
+			name: "condition or",
+			msg:  &messaging.Message{Condition: "'stock-GOOG' in topics || 'industry-tech' in topics"},
+			want: []string{"a", "c", "d"},
+		},
+		{
+			name: "condition and not",
+			msg:  &messaging.Message{Condition: "'industry-tech' in topics && !('stock-GOOG' in topics)"},
+			want: []string{"d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(srv.Messages())
+			resp, err := client.Send(ctx, tt.msg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.SuccessCount != 1 {
+				t.Fatalf("expected success, got %+v", resp.Responses[0].Error)
+			}
+			msgs := srv.Messages()
+			if len(msgs) != before+1 {
+				t.Fatalf("expected one recorded message, got %d", len(msgs)-before)
+			}
+			if got := msgs[len(msgs)-1].Delivered; !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("delivered to %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := len(srv.DeliveredTo("c")); got != 2 {
+		t.Fatalf("expected 2 messages delivered to c, got %d", got)
+	}
+
+	resp, err := client.Send(ctx, &messaging.Message{Condition: "'stock-GOOG' in topics &&"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.FailureCount != 1 || !messaging.IsInvalidArgument(resp.Responses[0].Error) {
+		t.Fatalf("expected invalid argument for malformed condition, got %+v", resp.Responses[0].Error)
+	}
+}
+
+func TestConditionReachesDevicesWithoutSubscriptions(t *testing.T) {
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+	srv.SetDevice("lonely", fcmtest.Device{Platform: "ANDROID"})
+	srv.Subscribe("a", "member")
+
+	client, err := fcm.NewClient(
+		context.Background(),
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.Send(context.Background(), &messaging.Message{Condition: "!('a' in topics)"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := srv.Messages()[0].Delivered; len(got) != 1 || got[0] != "lonely" {
+		t.Fatalf("Delivered = %v, want [lonely]", got)
+	}
+}
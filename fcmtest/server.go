@@ -0,0 +1,635 @@
+// Package fcmtest provides a local stand-in for the Firebase Cloud Messaging
+// HTTP v1, Instance ID and device group endpoints, for use in tests and
+// benchmarks.
+//
+// Point a Client at the fake server by routing its traffic through the
+// server's transport:
+//
+//	srv := fcmtest.NewServer()
+//	defer srv.Close()
+//
+//	client, err := fcm.NewClient(
+//		ctx,
+//		fcm.WithProjectID("test"),
+//		fcm.WithHTTPClient(srv.Client()),
+//	)
+package fcmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/condition"
+)
+
+// SentMessage is a message accepted by the fake messages:send endpoint.
+type SentMessage struct {
+	Name    string
+	Message *messaging.Message
+	DryRun  bool
+	// Delivered lists the fake device tokens the message would have reached:
+	// the Token target itself, the members of the Topic target, or every
+	// known device whose subscriptions satisfy the Condition target. Known
+	// devices are those registered with SetDevice or subscribed to at least
+	// one topic. It is empty for dry runs.
+	Delivered []string
+}
+
+// Server is a fake FCM backend. It keeps the topic memberships created through
+// the Instance ID batchAdd and batchRemove endpoints and routes Topic and
+// Condition sends to the subscribed device tokens.
+type Server struct {
+	// URL is the base URL of the server, of the form http://ipaddr:port with
+	// no trailing slash.
+	URL string
+
+	srv     *httptest.Server
+	latency atomic.Int64
+	seq     atomic.Int64
+	record  atomic.Bool
+	clock   atomic.Pointer[fcm.Clock]
+
+	mu       sync.Mutex
+	sent     []SentMessage
+	topics   map[string]map[string]time.Time // topic -> token -> subscribed at
+	failures map[string]string               // token -> FCM error code
+	devices  map[string]Device               // token -> app instance
+	groups   map[string]*deviceGroup         // notification_key_name -> group
+}
+
+type deviceGroup struct {
+	key     string
+	members map[string]struct{}
+}
+
+// Device describes the app instance behind a registration token, as the fake
+// Instance ID info endpoint reports it.
+type Device struct {
+	Application      string
+	AuthorizedEntity string
+	// Platform is ANDROID, IOS or CHROME.
+	Platform string
+}
+
+// NewServer starts and returns a new fake FCM server. The caller should call
+// Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		topics:   map[string]map[string]time.Time{},
+		failures: map[string]string{},
+		devices:  map[string]Device{},
+		groups:   map[string]*deviceGroup{},
+	}
+	s.record.Store(true)
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server and blocks until all outstanding requests on
+// it have completed.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// SetLatency makes every request wait for d before it is answered, to
+// approximate the round trip to the real FCM backend.
+func (s *Server) SetLatency(d time.Duration) {
+	s.latency.Store(int64(d))
+}
+
+// SetClock makes the server take the subscription times it reports and its
+// SetLatency waits from clock, such as a Clock also passed to fcm.WithClock.
+// The server uses the system clock until it is called.
+func (s *Server) SetClock(clock fcm.Clock) {
+	s.clock.Store(&clock)
+}
+
+// now returns the current time of the server's clock.
+func (s *Server) now() time.Time {
+	if c := s.clock.Load(); c != nil {
+		return (*c).Now()
+	}
+	return time.Now()
+}
+
+// after waits for d on the server's clock.
+func (s *Server) after(d time.Duration) <-chan time.Time {
+	if c := s.clock.Load(); c != nil {
+		return (*c).After(d)
+	}
+	return time.After(d)
+}
+
+// SetRecording controls whether accepted messages are kept for Messages.
+// Benchmarks turn it off so the server does not grow without bound.
+func (s *Server) SetRecording(record bool) {
+	s.record.Store(record)
+}
+
+// Messages returns a copy of the messages accepted so far.
+func (s *Server) Messages() []SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SentMessage(nil), s.sent...)
+}
+
+// SetDevice registers the app instance behind token for the Instance ID info
+// endpoint. Tokens with subscriptions are known even without it.
+func (s *Server) SetDevice(token string, d Device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.devices[token] = d
+}
+
+// FailToken makes every later send to token fail with the given FCM error
+// code, such as UNREGISTERED, INVALID_ARGUMENT or QUOTA_EXCEEDED, so tests can
+// exercise error handling. Topic management calls report the matching
+// Instance ID error for the token and leave its subscriptions unchanged. An
+// empty code clears the failure. UNAVAILABLE is retried by the Firebase SDK
+// with backoff, which slows tests down.
+func (s *Server) FailToken(token, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if code == "" {
+		delete(s.failures, token)
+		return
+	}
+	s.failures[token] = code
+}
+
+// Subscribe adds tokens to topic as if SubscribeTopic had been called, so
+// tests can seed memberships directly.
+func (s *Server) Subscribe(topic string, tokens ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribeLocked(strings.TrimPrefix(topic, "/topics/"), tokens)
+}
+
+// TopicMembers returns the sorted tokens currently subscribed to topic.
+func (s *Server) TopicMembers(topic string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedKeys(s.topics[strings.TrimPrefix(topic, "/topics/")])
+}
+
+// Subscriptions returns the sorted topics token is currently subscribed to.
+func (s *Server) Subscriptions(token string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedKeys(s.subscriptionsLocked(token))
+}
+
+// DeliveredTo returns the non-dry-run messages that reached token.
+func (s *Server) DeliveredTo(token string) []SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []SentMessage
+	for _, m := range s.sent {
+		if slices.Contains(m.Delivered, token) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Requests returns the number of messages:send requests served so far.
+func (s *Server) Requests() int64 {
+	return s.seq.Load()
+}
+
+// Client returns an HTTP client that delivers every request, regardless of
+// its original host, to the fake server. This covers the Instance ID topic
+// management endpoint, which the Firebase SDK does not let callers override.
+func (s *Server) Client() *http.Client {
+	return &http.Client{Transport: s.Transport(nil)}
+}
+
+// Transport wraps base so that every request is redirected to the fake
+// server. A nil base uses a clone of http.DefaultTransport.
+func (s *Server) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	target, _ := url.Parse(s.URL)
+	return &rewriteTransport{base: base, target: target}
+}
+
+type rewriteTransport struct {
+	base   http.RoundTripper
+	target *url.URL
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	r.Host = t.target.Host
+	return t.base.RoundTrip(r)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if d := time.Duration(s.latency.Load()); d > 0 {
+		select {
+		case <-s.after(d):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/messages:send"):
+		s.handleSend(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/iid/info/"):
+		s.handleInfo(w, r)
+	case r.URL.Path == "/fcm/notification":
+		s.handleDeviceGroup(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, ":batchImport"):
+		s.handleImport(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, ":batchAdd"),
+		r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, ":batchRemove"):
+		s.handleTopicManagement(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "unknown endpoint: "+r.URL.Path)
+	}
+}
+
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ValidateOnly bool               `json:"validate_only"`
+		Message      *messaging.Message `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Message == nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "request does not contain a valid message")
+		return
+	}
+
+	s.mu.Lock()
+	code, failed := s.failures[req.Message.Token]
+	s.mu.Unlock()
+	if failed && req.Message.Token != "" {
+		writeError(w, errorStatus(code), code, "request failed for token "+req.Message.Token)
+		return
+	}
+
+	if !s.record.Load() {
+		name := fmt.Sprintf("projects/%s/messages/%d", projectFromPath(r.URL.Path), s.seq.Add(1))
+		writeJSON(w, http.StatusOK, map[string]string{"name": name})
+		return
+	}
+
+	s.mu.Lock()
+	delivered, err := s.routeLocked(req.Message)
+	if err != nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	if req.ValidateOnly {
+		delivered = nil
+	}
+	name := fmt.Sprintf("projects/%s/messages/%d", projectFromPath(r.URL.Path), s.seq.Add(1))
+	s.sent = append(s.sent, SentMessage{
+		Name:      name,
+		Message:   req.Message,
+		DryRun:    req.ValidateOnly,
+		Delivered: delivered,
+	})
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]string{"name": name})
+}
+
+func (s *Server) handleTopicManagement(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		To     string   `json:"to"`
+		Tokens []string `json:"registration_tokens"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "InvalidRequest"}`, http.StatusBadRequest)
+		return
+	}
+
+	topic := strings.TrimPrefix(req.To, "/topics/")
+	results := make([]map[string]string, len(req.Tokens))
+	var tokens []string
+	s.mu.Lock()
+	for i, token := range req.Tokens {
+		results[i] = map[string]string{}
+		if code, ok := s.failures[token]; ok {
+			results[i]["error"] = topicErrorCode(code)
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	if strings.HasSuffix(r.URL.Path, ":batchAdd") {
+		s.subscribeLocked(topic, tokens)
+	} else {
+		for _, token := range tokens {
+			delete(s.topics[topic], token)
+		}
+		if len(s.topics[topic]) == 0 {
+			delete(s.topics, topic)
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/iid/info/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device, known := s.devices[token]
+	subs := s.subscriptionsLocked(token)
+	if _, failed := s.failures[token]; failed || (!known && len(subs) == 0) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "No information found about this instance id."})
+		return
+	}
+
+	info := map[string]any{
+		"application":      device.Application,
+		"authorizedEntity": device.AuthorizedEntity,
+		"platform":         device.Platform,
+	}
+	if r.URL.Query().Get("details") == "true" {
+		topics := map[string]any{}
+		for topic := range subs {
+			topics[topic] = map[string]string{
+				"addDate": s.topics[topic][token].Format("2006-01-02"),
+			}
+		}
+		info["rel"] = map[string]any{"topics": topics}
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleImport maps each APNs token to the registration token
+// "fcm-<apns token>" and registers it as an iOS device of the application.
+// APNs tokens made to fail with FailToken report their error code as status.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Application string   `json:"application"`
+		Sandbox     bool     `json:"sandbox"`
+		APNsTokens  []string `json:"apns_tokens"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Application == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "InvalidRequest"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]map[string]string, len(req.APNsTokens))
+	for i, apnsToken := range req.APNsTokens {
+		results[i] = map[string]string{"apns_token": apnsToken}
+		if code, ok := s.failures[apnsToken]; ok {
+			results[i]["status"] = code
+			continue
+		}
+		token := "fcm-" + apnsToken
+		s.devices[token] = Device{Application: req.Application, Platform: "IOS"}
+		results[i]["status"] = "OK"
+		results[i]["registration_token"] = token
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+// DeviceGroup returns the notification_key and sorted members of the device
+// group name, or false if there is none.
+func (s *Server) DeviceGroup(name string) (string, []string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.groups[name]
+	if !ok {
+		return "", nil, false
+	}
+	return g.key, sortedKeys(g.members), true
+}
+
+// handleDeviceGroup serves the device group API: GET looks up a
+// notification_key by name, POST creates a group or adds or removes members.
+// Tokens made to fail with FailToken are rejected as invalid registration
+// IDs.
+func (s *Server) handleDeviceGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("project_id") == "" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "project_id header is required"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method == http.MethodGet {
+		g, ok := s.groups[r.URL.Query().Get("notification_key_name")]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "notification_key not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"notification_key": g.key})
+		return
+	}
+
+	var req struct {
+		Operation string   `json:"operation"`
+		Key       string   `json:"notification_key"`
+		Name      string   `json:"notification_key_name"`
+		Tokens    []string `json:"registration_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "InvalidRequest"})
+		return
+	}
+	var tokens []string
+	for _, token := range req.Tokens {
+		if _, failed := s.failures[token]; !failed {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no valid registration ids"})
+		return
+	}
+
+	g := s.groups[req.Name]
+	switch req.Operation {
+	case "create":
+		if g != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "notification_key already exists"})
+			return
+		}
+		g = &deviceGroup{
+			key:     fmt.Sprintf("APA91b-group-%d", s.seq.Add(1)),
+			members: map[string]struct{}{},
+		}
+		s.groups[req.Name] = g
+	case "add", "remove":
+		if g == nil || g.key != req.Key {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "notification_key not found"})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid operation"})
+		return
+	}
+	for _, token := range tokens {
+		if req.Operation == "remove" {
+			delete(g.members, token)
+		} else {
+			g.members[token] = struct{}{}
+		}
+	}
+	if len(g.members) == 0 {
+		delete(s.groups, req.Name)
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"notification_key": g.key})
+}
+
+func (s *Server) groupByKeyLocked(key string) *deviceGroup {
+	for _, g := range s.groups {
+		if g.key == key {
+			return g
+		}
+	}
+	return nil
+}
+
+func (s *Server) subscribeLocked(topic string, tokens []string) {
+	members := s.topics[topic]
+	if members == nil {
+		members = map[string]time.Time{}
+		s.topics[topic] = members
+	}
+	now := s.now()
+	for _, token := range tokens {
+		if _, ok := members[token]; !ok {
+			members[token] = now
+		}
+	}
+}
+
+func (s *Server) subscriptionsLocked(token string) map[string]struct{} {
+	subs := map[string]struct{}{}
+	for topic, members := range s.topics {
+		if _, ok := members[token]; ok {
+			subs[topic] = struct{}{}
+		}
+	}
+	return subs
+}
+
+// routeLocked resolves the device tokens a message targets.
+func (s *Server) routeLocked(m *messaging.Message) ([]string, error) {
+	switch {
+	case m.Token != "":
+		if g := s.groupByKeyLocked(m.Token); g != nil {
+			return sortedKeys(g.members), nil
+		}
+		return []string{m.Token}, nil
+	case m.Topic != "":
+		return sortedKeys(s.topics[m.Topic]), nil
+	case m.Condition != "":
+		devices := map[string]struct{}{}
+		for token := range s.devices {
+			devices[token] = struct{}{}
+		}
+		for _, members := range s.topics {
+			for token := range members {
+				devices[token] = struct{}{}
+			}
+		}
+		expr, err := condition.Parse(m.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition: %w", err)
+		}
+		var delivered []string
+		for _, token := range sortedKeys(devices) {
+			if condition.Evaluate(expr, sortedKeys(s.subscriptionsLocked(token))) {
+				delivered = append(delivered, token)
+			}
+		}
+		return delivered, nil
+	}
+	return nil, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// projectFromPath extracts the project ID from a .../projects/{id}/messages:send path.
+func projectFromPath(path string) string {
+	_, rest, ok := strings.Cut(path, "/projects/")
+	if !ok {
+		return ""
+	}
+	project, _, _ := strings.Cut(rest, "/")
+	return project
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// topicErrorCode returns the per-token error the Instance ID API reports for
+// a token failing with the FCM error code.
+func topicErrorCode(code string) string {
+	if code == "UNREGISTERED" {
+		return "NOT_FOUND"
+	}
+	return code
+}
+
+// errorStatus returns the HTTP status FCM answers with for an error code.
+func errorStatus(code string) int {
+	switch code {
+	case "UNREGISTERED", "NOT_FOUND":
+		return http.StatusNotFound
+	case "SENDER_ID_MISMATCH", "PERMISSION_DENIED":
+		return http.StatusForbidden
+	case "THIRD_PARTY_AUTH_ERROR", "UNAUTHENTICATED":
+		return http.StatusUnauthorized
+	case "QUOTA_EXCEEDED":
+		return http.StatusTooManyRequests
+	case "UNAVAILABLE":
+		return http.StatusServiceUnavailable
+	case "INTERNAL":
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+// writeError answers with the error shape of the FCM v1 API so the SDK maps
+// errorCode to its messaging.Is* helpers.
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{
+			"code":    status,
+			"message": msg,
+			"status":  code,
+			"details": []map[string]string{{
+				"@type":     "type.googleapis.com/google.firebase.fcm.v1.FcmError",
+				"errorCode": code,
+			}},
+		},
+	})
+}
@@ -0,0 +1,97 @@
+package fcmtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServerSendAndTopicManagement(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	// The client rewrites the host, so the real FCM URL reaches the fake.
+	body := []byte(`{"validate_only": true, "message": {"token": "abc", "data": {"foo": "bar"}}}`)
+	resp, err := srv.Client().Post(
+		"https://fcm.googleapis.com/v1/projects/demo/messages:send",
+		"application/json",
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	var got struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Name != "projects/demo/messages/1" {
+		t.Fatalf("unexpected message name %q", got.Name)
+	}
+
+	msgs := srv.Messages()
+	if len(msgs) != 1 || !msgs[0].DryRun || msgs[0].Message.Token != "abc" {
+		t.Fatalf("unexpected recorded messages: %+v", msgs)
+	}
+
+	resp, err = srv.Client().Post(
+		"https://iid.googleapis.com/iid/v1:batchAdd",
+		"application/json",
+		bytes.NewReader([]byte(`{"to": "/topics/news", "registration_tokens": ["a", "b"]}`)),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	var iid struct {
+		Results []map[string]string `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&iid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(iid.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(iid.Results))
+	}
+}
+
+func TestServerLatencyUsesClock(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	clock := NewClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	srv.SetClock(clock)
+	srv.SetLatency(time.Minute)
+
+	done := make(chan error, 1)
+	go func() {
+		body := []byte(`{"message": {"token": "abc"}}`)
+		resp, err := srv.Client().Post(
+			"https://fcm.googleapis.com/v1/projects/demo/messages:send",
+			"application/json",
+			bytes.NewReader(body),
+		)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+
+	for clock.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatal("expected the request to wait for the fake clock")
+	default:
+	}
+	clock.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package fcm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// defaultIIDEndpoint is the Instance ID API, which WithInstanceIDEndpoint
+// replaces.
+const defaultIIDEndpoint = "https://iid.googleapis.com"
+
+// iidDateLayout is the date format of the Instance ID info response.
+const iidDateLayout = "2006-01-02"
+
+// InstanceIDError is returned when the Instance ID API rejects a request.
+type InstanceIDError struct {
+	StatusCode int
+	// Reason is the error reported by the API, such as "InvalidToken".
+	Reason string
+}
+
+func (e *InstanceIDError) Error() string {
+	return fmt.Sprintf("instance id request failed with status %d: %s", e.StatusCode, e.Reason)
+}
+
+// TokenInfo describes the app instance behind a registration token, as
+// reported by the Instance ID API.
+type TokenInfo struct {
+	// Application is the package name or bundle ID of the app.
+	Application        string
+	ApplicationVersion string
+	// AuthorizedEntity is the project number allowed to send to the token.
+	AuthorizedEntity string
+	// Platform is ANDROID, IOS or CHROME.
+	Platform       string
+	AppSigner      string
+	AttestStatus   string
+	ConnectionType string
+	ConnectedAt    time.Time
+	// Topics lists the topic subscriptions, sorted by name. It is only set
+	// when requested.
+	Topics []TopicSubscription
+}
+
+// TopicSubscription is a topic a registration token is subscribed to.
+type TopicSubscription struct {
+	Name    string
+	AddedAt time.Time
+}
+
+type tokenInfoResponse struct {
+	Application        string `json:"application"`
+	ApplicationVersion string `json:"applicationVersion"`
+	AuthorizedEntity   string `json:"authorizedEntity"`
+	Platform           string `json:"platform"`
+	AppSigner          string `json:"appSigner"`
+	AttestStatus       string `json:"attestStatus"`
+	ConnectionType     string `json:"connectionType"`
+	ConnectDate        string `json:"connectDate"`
+	Rel                struct {
+		Topics map[string]struct {
+			AddDate string `json:"addDate"`
+		} `json:"topics"`
+	} `json:"rel"`
+}
+
+// GetTokenInfo looks up the app instance behind token through the Instance
+// ID API, including its topic subscriptions when withTopics is set. The
+// request uses the Client's credentials and transport, so WithHTTPClient,
+// WithHTTPProxy and WithDebug apply to it.
+func (c *Client) GetTokenInfo(ctx context.Context, token string, withTopics bool) (*TokenInfo, error) {
+	if token == "" {
+		return nil, errors.New("token must not be empty")
+	}
+	path := "/iid/info/" + url.PathEscape(token)
+	if withTopics {
+		path += "?details=true"
+	}
+	var resp tokenInfoResponse
+	if err := c.iidRequest(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	info := &TokenInfo{
+		Application:        resp.Application,
+		ApplicationVersion: resp.ApplicationVersion,
+		AuthorizedEntity:   resp.AuthorizedEntity,
+		Platform:           resp.Platform,
+		AppSigner:          resp.AppSigner,
+		AttestStatus:       resp.AttestStatus,
+		ConnectionType:     resp.ConnectionType,
+		ConnectedAt:        parseIIDDate(resp.ConnectDate),
+	}
+	for name, t := range resp.Rel.Topics {
+		info.Topics = append(info.Topics, TopicSubscription{Name: name, AddedAt: parseIIDDate(t.AddDate)})
+	}
+	sort.Slice(info.Topics, func(i, j int) bool { return info.Topics[i].Name < info.Topics[j].Name })
+	return info, nil
+}
+
+// parseIIDDate parses a date of the Instance ID API, returning the zero time
+// when it is missing or malformed.
+func parseIIDDate(s string) time.Time {
+	t, _ := time.Parse(iidDateLayout, s)
+	return t
+}
+
+// iidRequest sends an authorized request to the Instance ID API, encoding
+// body as JSON when it is not nil and decoding the response into out.
+func (c *Client) iidRequest(ctx context.Context, method, path string, body, out any) error {
+	endpoint := c.iidEndpoint
+	if endpoint == "" {
+		endpoint = defaultIIDEndpoint
+	}
+	return c.jsonRequest(ctx, endpoint, method, path, nil, body, out,
+		func(status int, reason string) error {
+			return &InstanceIDError{StatusCode: status, Reason: reason}
+		})
+}
+
+// jsonRequest sends an authorized request to path under endpoint. A response
+// other than 200 OK is turned into an error by newErr, from its status and
+// the "error" field of its body.
+func (c *Client) jsonRequest(
+	ctx context.Context,
+	endpoint, method, path string,
+	header http.Header,
+	body, out any,
+	newErr func(status int, reason string) error,
+) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+path, r)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	// The Instance ID and device group APIs only accept OAuth2 access tokens
+	// with this header.
+	req.Header.Set("access_token_auth", "true")
+
+	resp, err := c.authClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) != nil || e.Error == "" {
+			e.Error = http.StatusText(resp.StatusCode)
+		}
+		return newErr(resp.StatusCode, e.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("cannot decode response: %w", err)
+	}
+	return nil
+}
+
+// maxAPNsImportBatchSize is the largest number of APNs tokens the Instance ID
+// batchImport endpoint accepts per request.
+const maxAPNsImportBatchSize = 100
+
+// APNsImportResult is the outcome of importing one APNs device token.
+type APNsImportResult struct {
+	APNsToken string
+	// Token is the FCM registration token the APNs token maps to, or empty
+	// when the import failed.
+	Token string
+	Err   error
+}
+
+type apnsImportRequest struct {
+	Application string   `json:"application"`
+	Sandbox     bool     `json:"sandbox"`
+	APNsTokens  []string `json:"apns_tokens"`
+}
+
+type apnsImportResponse struct {
+	Results []struct {
+		APNsToken         string `json:"apns_token"`
+		Status            string `json:"status"`
+		RegistrationToken string `json:"registration_token"`
+	} `json:"results"`
+}
+
+// ImportAPNsTokens converts APNs device tokens of the iOS app bundleID into
+// FCM registration tokens through the Instance ID batchImport endpoint, in
+// requests of up to 100 tokens. sandbox selects the APNs development
+// environment. The results are indexed like apnsTokens. When a request fails
+// as a whole, its tokens carry the error and the returned error joins those
+// of every failed request.
+func (c *Client) ImportAPNsTokens(
+	ctx context.Context,
+	bundleID string,
+	sandbox bool,
+	apnsTokens []string,
+) ([]APNsImportResult, error) {
+	if bundleID == "" {
+		return nil, errors.New("bundle ID must not be empty")
+	}
+	if len(apnsTokens) == 0 {
+		return nil, errors.New("apns tokens must not be empty")
+	}
+
+	results := make([]APNsImportResult, len(apnsTokens))
+	var errs []error
+	for start := 0; start < len(apnsTokens); start += maxAPNsImportBatchSize {
+		end := min(start+maxAPNsImportBatchSize, len(apnsTokens))
+		batch := apnsTokens[start:end]
+		var resp apnsImportResponse
+		err := c.iidRequest(ctx, http.MethodPost, "/iid/v1:batchImport", apnsImportRequest{
+			Application: bundleID,
+			Sandbox:     sandbox,
+			APNsTokens:  batch,
+		}, &resp)
+		if err == nil && len(resp.Results) != len(batch) {
+			err = fmt.Errorf("got %d results for %d tokens", len(resp.Results), len(batch))
+		}
+		if err != nil {
+			for i := start; i < end; i++ {
+				results[i] = APNsImportResult{APNsToken: apnsTokens[i], Err: err}
+			}
+			errs = append(errs, fmt.Errorf("cannot import apns tokens %d to %d: %w", start, end-1, err))
+			continue
+		}
+
+		for i, r := range resp.Results {
+			result := APNsImportResult{APNsToken: apnsTokens[start+i]}
+			if r.Status == "OK" {
+				result.Token = r.RegistrationToken
+			} else {
+				result.Err = fmt.Errorf("cannot import apns token: %s", r.Status)
+			}
+			results[start+i] = result
+		}
+	}
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,135 @@
+package fcm_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+	"golang.org/x/oauth2"
+)
+
+func TestGetTokenInfo(t *testing.T) {
+	ctx := context.Background()
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+	added := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	srv.SetClock(fcmtest.NewClock(added))
+	srv.SetDevice("t1", fcmtest.Device{Application: "com.example.app", AuthorizedEntity: "1234", Platform: "ANDROID"})
+	srv.Subscribe("news", "t1")
+	srv.Subscribe("en", "t1")
+
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithInstanceIDEndpoint(srv.URL),
+		fcm.WithProjectID("test"),
+		fcm.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "test-token"})),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := client.GetTokenInfo(ctx, "t1", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Application != "com.example.app" || info.Platform != "ANDROID" || info.AuthorizedEntity != "1234" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	if len(info.Topics) != 2 || info.Topics[0].Name != "en" || info.Topics[1].Name != "news" ||
+		!info.Topics[0].AddedAt.Equal(added.Truncate(24*time.Hour)) {
+		t.Fatalf("unexpected topics: %+v", info.Topics)
+	}
+
+	info, err = client.GetTokenInfo(ctx, "t1", false)
+	if err != nil || len(info.Topics) != 0 {
+		t.Fatalf("expected no topics without details, got %+v %v", info, err)
+	}
+
+	var iidErr *fcm.InstanceIDError
+	if _, err := client.GetTokenInfo(ctx, "unknown", true); !errors.As(err, &iidErr) || iidErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a not found InstanceIDError, got %v", err)
+	}
+}
+
+func TestGetTokenInfoAuthorization(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" || r.Header.Get("access_token_auth") != "true" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"Unauthorized"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"application":"com.example.app","platform":"IOS","connectDate":"2024-05-01"}`))
+	}))
+	defer server.Close()
+
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithInstanceIDEndpoint(server.URL),
+		fcm.WithProjectID("test"),
+		fcm.WithDebug(true),
+		fcm.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "test-token"})),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info, err := client.GetTokenInfo(ctx, "t1", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Platform != "IOS" || info.ConnectedAt.Format("2006-01-02") != "2024-05-01" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+}
+
+func TestImportAPNsTokens(t *testing.T) {
+	ctx := context.Background()
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+	srv.FailToken("apns-7", "Internal Server Error")
+
+	client, err := fcm.NewClient(ctx, fcm.WithProjectID("test"), fcm.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	apnsTokens := make([]string, 150)
+	for i := range apnsTokens {
+		apnsTokens[i] = fmt.Sprintf("apns-%d", i)
+	}
+	results, err := client.ImportAPNsTokens(ctx, "com.example.app", true, apnsTokens)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 150 {
+		t.Fatalf("expected 150 results, got %d", len(results))
+	}
+	for i, r := range results {
+		if r.APNsToken != apnsTokens[i] {
+			t.Fatalf("result %d is for %s", i, r.APNsToken)
+		}
+		if i == 7 {
+			if r.Err == nil || r.Token != "" {
+				t.Fatalf("expected result 7 to fail, got %+v", r)
+			}
+			continue
+		}
+		if r.Err != nil || r.Token != "fcm-"+apnsTokens[i] {
+			t.Fatalf("unexpected result %d: %+v", i, r)
+		}
+	}
+
+	info, err := client.GetTokenInfo(ctx, results[149].Token, false)
+	if err != nil || info.Platform != "IOS" || info.Application != "com.example.app" {
+		t.Fatalf("expected the imported token to be registered, got %+v %v", info, err)
+	}
+	if _, err := client.ImportAPNsTokens(ctx, "", false, apnsTokens); err == nil {
+		t.Fatal("expected error for an empty bundle ID, got nil")
+	}
+}
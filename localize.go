@@ -0,0 +1,199 @@
+package fcm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// ErrNoLocalizedContent is reported for recipients whose locale, base
+// language and fallback chain all lack content.
+var ErrNoLocalizedContent = errors.New("no localized content for recipient locale")
+
+// LocalizedContent is the notification text for one locale.
+type LocalizedContent struct {
+	Title string
+	Body  string
+}
+
+// LocalizedRecipient is a registration token with the locale of its device,
+// as a BCP 47 tag such as "pt-BR" (underscores are accepted too).
+type LocalizedRecipient struct {
+	Token  string
+	Locale string
+}
+
+// LocalizedMessage describes a notification sent in each recipient's
+// language.
+type LocalizedMessage struct {
+	// Content maps a locale, such as "en" or "pt-BR", to its notification.
+	Content map[string]LocalizedContent
+	// Fallback lists the locales tried, in order, when neither a recipient's
+	// locale nor its base language has content.
+	Fallback []string
+	// Base carries the fields shared by every locale, such as Data and the
+	// platform configs. Its Tokens and Notification are ignored.
+	Base *messaging.MulticastMessage
+}
+
+// ResolveLocale picks the locale of content to use for locale: the locale
+// itself, then its base language ("pt" for "pt-BR"), then each fallback in
+// order. Matching ignores case and treats "_" like "-". It reports false when
+// nothing matches.
+func ResolveLocale[V any](locale string, content map[string]V, fallback []string) (string, bool) {
+	index := make(map[string]string, len(content))
+	for k := range content {
+		index[normalizeLocale(k)] = k
+	}
+
+	candidates := []string{locale}
+	if base, _, ok := strings.Cut(normalizeLocale(locale), "-"); ok {
+		candidates = append(candidates, base)
+	}
+	candidates = append(candidates, fallback...)
+	for _, c := range candidates {
+		if k, ok := index[normalizeLocale(c)]; ok {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// GroupByLocale resolves every recipient's locale against content and returns
+// the indexes of the recipients per resolved locale, plus the indexes of the
+// recipients that could not be resolved.
+func GroupByLocale[V any](
+	recipients []LocalizedRecipient,
+	content map[string]V,
+	fallback []string,
+) (map[string][]int, []int) {
+	groups := map[string][]int{}
+	var unresolved []int
+	for i, r := range recipients {
+		locale, ok := ResolveLocale(r.Locale, content, fallback)
+		if !ok {
+			unresolved = append(unresolved, i)
+			continue
+		}
+		groups[locale] = append(groups[locale], i)
+	}
+	return groups, unresolved
+}
+
+// SendLocalized groups recipients by resolved locale and sends one multicast
+// per group (split into chunks of 500 tokens), merging the results. The
+// returned BatchResponse has one entry per recipient, in input order;
+// recipients without content fail with ErrNoLocalizedContent and are not
+// sent.
+func (c *Client) SendLocalized(
+	ctx context.Context,
+	message *LocalizedMessage,
+	recipients []LocalizedRecipient,
+) (*messaging.BatchResponse, error) {
+	if message == nil || len(message.Content) == 0 {
+		return nil, errors.New("localized message must have content")
+	}
+
+	out := &messaging.BatchResponse{
+		Responses: make([]*messaging.SendResponse, len(recipients)),
+	}
+	groups, unresolved := GroupByLocale(recipients, message.Content, message.Fallback)
+	for _, i := range unresolved {
+		out.Responses[i] = &messaging.SendResponse{
+			Error: fmt.Errorf("%w: %q", ErrNoLocalizedContent, recipients[i].Locale),
+		}
+		out.FailureCount++
+	}
+
+	locales := make([]string, 0, len(groups))
+	for locale := range groups {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+
+	for _, locale := range locales {
+		content := message.Content[locale]
+		indexes := groups[locale]
+		for start := 0; start < len(indexes); start += maxBatchSize {
+			chunk := indexes[start:min(start+maxBatchSize, len(indexes))]
+
+			var mm messaging.MulticastMessage
+			if message.Base != nil {
+				mm = *message.Base
+			}
+			mm.Notification = &messaging.Notification{Title: content.Title, Body: content.Body}
+			mm.Tokens = make([]string, len(chunk))
+			for j, i := range chunk {
+				mm.Tokens[j] = recipients[i].Token
+			}
+
+			resp, err := c.SendMulticast(ctx, &mm)
+			if err != nil {
+				return out, fmt.Errorf("cannot send locale %q: %w", locale, err)
+			}
+			for j, r := range resp.Responses {
+				out.Responses[chunk[j]] = r
+			}
+			out.SuccessCount += resp.SuccessCount
+			out.FailureCount += resp.FailureCount
+		}
+	}
+	return out, nil
+}
+
+// LocKeys names string resources in the client app that hold the
+// notification text, so the device localizes it itself.
+type LocKeys struct {
+	TitleKey  string
+	TitleArgs []string
+	BodyKey   string
+	BodyArgs  []string
+}
+
+// Apply sets the Android title_loc_key/body_loc_key and the APNs
+// title-loc-key/loc-key (with their arguments) on msg, creating the platform
+// configs as needed.
+func (k LocKeys) Apply(msg *messaging.Message) {
+	k.apply(&msg.Android, &msg.APNS)
+}
+
+// ApplyMulticast is Apply for a multicast message.
+func (k LocKeys) ApplyMulticast(msg *messaging.MulticastMessage) {
+	k.apply(&msg.Android, &msg.APNS)
+}
+
+func (k LocKeys) apply(android **messaging.AndroidConfig, apns **messaging.APNSConfig) {
+	if *android == nil {
+		*android = &messaging.AndroidConfig{}
+	}
+	if (*android).Notification == nil {
+		(*android).Notification = &messaging.AndroidNotification{}
+	}
+	n := (*android).Notification
+	n.TitleLocKey, n.TitleLocArgs = k.TitleKey, k.TitleArgs
+	n.BodyLocKey, n.BodyLocArgs = k.BodyKey, k.BodyArgs
+
+	if *apns == nil {
+		*apns = &messaging.APNSConfig{}
+	}
+	if (*apns).Payload == nil {
+		(*apns).Payload = &messaging.APNSPayload{}
+	}
+	if (*apns).Payload.Aps == nil {
+		(*apns).Payload.Aps = &messaging.Aps{}
+	}
+	aps := (*apns).Payload.Aps
+	if aps.Alert == nil {
+		aps.Alert = &messaging.ApsAlert{}
+	}
+	aps.Alert.TitleLocKey, aps.Alert.TitleLocArgs = k.TitleKey, k.TitleArgs
+	aps.Alert.LocKey, aps.Alert.LocArgs = k.BodyKey, k.BodyArgs
+}
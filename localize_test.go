@@ -0,0 +1,97 @@
+package fcm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"firebase.google.com/go/v4/messaging"
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+)
+
+func TestResolveLocale(t *testing.T) {
+	content := map[string]fcm.LocalizedContent{"en": {}, "pt-BR": {}, "de": {}}
+	tests := []struct {
+		locale   string
+		fallback []string
+		want     string
+		ok       bool
+	}{
+		{locale: "pt_br", want: "pt-BR", ok: true},
+		{locale: "de-AT", want: "de", ok: true},
+		{locale: "fr-FR", fallback: []string{"es", "en"}, want: "en", ok: true},
+		{locale: "fr-FR"},
+	}
+	for _, tt := range tests {
+		got, ok := fcm.ResolveLocale(tt.locale, content, tt.fallback)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ResolveLocale(%q) = %q, %v; want %q, %v", tt.locale, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSendLocalized(t *testing.T) {
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+
+	client, err := fcm.NewClient(
+		context.Background(),
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msg := &fcm.LocalizedMessage{
+		Content: map[string]fcm.LocalizedContent{
+			"en": {Title: "Hello", Body: "Welcome"},
+			"de": {Title: "Hallo", Body: "Willkommen"},
+		},
+		Base: &messaging.MulticastMessage{Data: map[string]string{"campaign": "42"}},
+	}
+	recipients := []fcm.LocalizedRecipient{
+		{Token: "a", Locale: "de-DE"},
+		{Token: "b", Locale: "en"},
+		{Token: "c", Locale: "ja"},
+	}
+
+	resp, err := client.SendLocalized(context.Background(), msg, recipients)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.SuccessCount != 2 || resp.FailureCount != 1 {
+		t.Fatalf("unexpected counts: success=%d failure=%d", resp.SuccessCount, resp.FailureCount)
+	}
+	if !errors.Is(resp.Responses[2].Error, fcm.ErrNoLocalizedContent) {
+		t.Fatalf("expected ErrNoLocalizedContent, got %v", resp.Responses[2].Error)
+	}
+
+	got := srv.DeliveredTo("a")
+	if len(got) != 1 || got[0].Message.Notification.Title != "Hallo" || got[0].Message.Data["campaign"] != "42" {
+		t.Fatalf("unexpected delivery to a: %+v", got)
+	}
+
+	// With a fallback chain the Japanese recipient gets English.
+	msg.Fallback = []string{"en"}
+	resp, err = client.SendLocalized(context.Background(), msg, recipients[2:])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.SuccessCount != 1 {
+		t.Fatalf("expected fallback delivery, got %v", resp.Responses[0].Error)
+	}
+}
+
+func TestLocKeysApply(t *testing.T) {
+	msg := &messaging.Message{Token: "a"}
+	fcm.LocKeys{TitleKey: "title_key", BodyKey: "body_key", BodyArgs: []string{"Ada"}}.Apply(msg)
+
+	if n := msg.Android.Notification; n.TitleLocKey != "title_key" || n.BodyLocKey != "body_key" || n.BodyLocArgs[0] != "Ada" {
+		t.Fatalf("unexpected android notification: %+v", n)
+	}
+	if a := msg.APNS.Payload.Aps.Alert; a.TitleLocKey != "title_key" || a.LocKey != "body_key" || a.LocArgs[0] != "Ada" {
+		t.Fatalf("unexpected aps alert: %+v", a)
+	}
+}
@@ -0,0 +1,273 @@
+package fcm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// Data keys carrying the multi-part metadata added by SplitData.
+const (
+	MultipartIDKey    = "fcm_part_id"
+	MultipartIndexKey = "fcm_part_index"
+	MultipartCountKey = "fcm_part_count"
+	MultipartDataKey  = "fcm_part_data"
+)
+
+// multipartIDBytes is the number of random bytes in a correlation ID.
+const multipartIDBytes = 8
+
+// SplitData splits a message whose data payload pushes it over
+// MaxPayloadSize into parts that each fit. The data map is encoded as JSON
+// and cut into consecutive chunks; every part carries one chunk plus a shared
+// correlation ID, its index and the part count, and keeps the target and the
+// Android, APNs and Webpush configs (including collapse settings) of the
+// original. The notification, APNs alert and sound, and Android and Webpush
+// notifications are only attached to the last part so the user is alerted
+// once. A message that already fits is returned unchanged as
+// the only part.
+func SplitData(message *messaging.Message) ([]*messaging.Message, error) {
+	if message == nil {
+		return nil, errors.New("message must not be nil")
+	}
+	size, _, err := PayloadSize(message)
+	if err != nil {
+		return nil, err
+	}
+	if size <= MaxPayloadSize {
+		return []*messaging.Message{message}, nil
+	}
+
+	payload, err := json.Marshal(message.Data)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newMultipartID()
+	if err != nil {
+		return nil, err
+	}
+
+	base := *message
+	base.Data = nil
+	overhead, _, err := PayloadSize(&base)
+	if err != nil {
+		return nil, err
+	}
+	// Reserve room for the metadata entries, assuming up to five digit counts.
+	overhead += len(MultipartIDKey) + len(id) +
+		len(MultipartIndexKey) + 5 + len(MultipartCountKey) + 5 + len(MultipartDataKey)
+	budget := MaxPayloadSize - overhead
+	if budget < utf8.UTFMax {
+		return nil, fmt.Errorf("message leaves no room for data: %d bytes of overhead", overhead)
+	}
+
+	chunks := splitUTF8(string(payload), budget)
+	silent := withoutAlerts(&base)
+	parts := make([]*messaging.Message, len(chunks))
+	for i, chunk := range chunks {
+		part := base
+		if i != len(chunks)-1 {
+			part = *silent
+		}
+		part.Data = map[string]string{
+			MultipartIDKey:    id,
+			MultipartIndexKey: strconv.Itoa(i),
+			MultipartCountKey: strconv.Itoa(len(chunks)),
+			MultipartDataKey:  chunk,
+		}
+		parts[i] = &part
+	}
+	return parts, nil
+}
+
+// SendMultipart sends message through Send, first splitting it with SplitData
+// when its data payload is too large for a single message.
+func (c *Client) SendMultipart(
+	ctx context.Context,
+	message *messaging.Message,
+) (*messaging.BatchResponse, error) {
+	parts, err := SplitData(message)
+	if err != nil {
+		return nil, err
+	}
+	return c.Send(ctx, parts...)
+}
+
+// withoutAlerts returns a copy of m without anything that alerts the user,
+// copying the configs it changes so m is left untouched.
+func withoutAlerts(m *messaging.Message) *messaging.Message {
+	silent := *m
+	silent.Notification = nil
+	if m.Android != nil && m.Android.Notification != nil {
+		android := *m.Android
+		android.Notification = nil
+		silent.Android = &android
+	}
+	if m.Webpush != nil && m.Webpush.Notification != nil {
+		webpush := *m.Webpush
+		webpush.Notification = nil
+		silent.Webpush = &webpush
+	}
+	if m.APNS != nil && m.APNS.Payload != nil && m.APNS.Payload.Aps != nil {
+		apns := *m.APNS
+		payload := *apns.Payload
+		aps := *payload.Aps
+		aps.Alert = nil
+		aps.AlertString = ""
+		aps.Sound = ""
+		aps.CriticalSound = nil
+		payload.Aps = &aps
+		apns.Payload = &payload
+		silent.APNS = &apns
+	}
+	return &silent
+}
+
+func newMultipartID() (string, error) {
+	b := make([]byte, multipartIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// splitUTF8 cuts s into chunks of at most n bytes without splitting a UTF-8
+// sequence.
+func splitUTF8(s string, n int) []string {
+	var chunks []string
+	for len(s) > n {
+		cut := n
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			// Not valid UTF-8; cut at n rather than make no progress.
+			cut = n
+		}
+		chunks = append(chunks, s[:cut])
+		s = s[cut:]
+	}
+	return append(chunks, s)
+}
+
+// Reassembler rebuilds data payloads split by SplitData on the receiving
+// side. Feed it the data of every received message; it is safe for
+// concurrent use.
+type Reassembler struct {
+	timeout time.Duration
+	clock   Clock
+
+	mu      sync.Mutex
+	pending map[string]*partialData
+}
+
+type partialData struct {
+	chunks   []string
+	received int
+	deadline time.Time
+}
+
+// NewReassembler returns a Reassembler that gives up on a split payload when
+// its parts have not all arrived within timeout of the first one. A nil clock
+// uses the system clock.
+func NewReassembler(timeout time.Duration, clock Clock) *Reassembler {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &Reassembler{
+		timeout: timeout,
+		clock:   clock,
+		pending: map[string]*partialData{},
+	}
+}
+
+// Add records the data of a received message. It returns the original data
+// map and true once every part of its payload has arrived. Data without
+// multi-part metadata is returned as is.
+func (r *Reassembler) Add(data map[string]string) (map[string]string, bool, error) {
+	id, ok := data[MultipartIDKey]
+	if !ok {
+		return data, true, nil
+	}
+	index, err := strconv.Atoi(data[MultipartIndexKey])
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid %s: %w", MultipartIndexKey, err)
+	}
+	count, err := strconv.Atoi(data[MultipartCountKey])
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid %s: %w", MultipartCountKey, err)
+	}
+	if count <= 0 || index < 0 || index >= count {
+		return nil, false, fmt.Errorf("part %d of %d is out of range", index, count)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expireLocked()
+
+	p := r.pending[id]
+	if p == nil {
+		p = &partialData{
+			chunks:   make([]string, count),
+			deadline: r.clock.Now().Add(r.timeout),
+		}
+		r.pending[id] = p
+	}
+	if len(p.chunks) != count {
+		return nil, false, fmt.Errorf("part %d of %s reports %d parts, expected %d", index, id, count, len(p.chunks))
+	}
+	if p.chunks[index] == "" {
+		p.received++
+	}
+	p.chunks[index] = data[MultipartDataKey]
+	if p.received < count {
+		return nil, false, nil
+	}
+
+	delete(r.pending, id)
+	var payload []byte
+	for _, c := range p.chunks {
+		payload = append(payload, c...)
+	}
+	var out map[string]string
+	if err := json.Unmarshal(payload, &out); err != nil {
+		return nil, false, fmt.Errorf("cannot decode reassembled payload %s: %w", id, err)
+	}
+	return out, true, nil
+}
+
+// Expire drops every incomplete payload whose parts did not all arrive in
+// time and returns their correlation IDs. Add calls it implicitly.
+func (r *Reassembler) Expire() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.expireLocked()
+}
+
+// Pending returns the number of payloads still waiting for parts.
+func (r *Reassembler) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
+
+func (r *Reassembler) expireLocked() []string {
+	now := r.clock.Now()
+	var expired []string
+	for id, p := range r.pending {
+		if now.After(p.deadline) {
+			delete(r.pending, id)
+			expired = append(expired, id)
+		}
+	}
+	return expired
+}
@@ -0,0 +1,140 @@
+package fcm_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+)
+
+func TestSendMultipartAndReassemble(t *testing.T) {
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+
+	client, err := fcm.NewClient(
+		context.Background(),
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+		fcm.WithPreflightValidation(true),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data := map[string]string{"small": "ok"}
+	for i := 0; i < 10; i++ {
+		data[fmt.Sprintf("blob%d", i)] = strings.Repeat("é", 600)
+	}
+	msg := &messaging.Message{
+		Token:        "device",
+		Data:         data,
+		Notification: &messaging.Notification{Title: "Sync ready"},
+		Android:      &messaging.AndroidConfig{CollapseKey: "sync"},
+		APNS: &messaging.APNSConfig{Payload: &messaging.APNSPayload{
+			Aps: &messaging.Aps{AlertString: "Sync ready", Sound: "default"},
+		}},
+	}
+
+	resp, err := client.SendMultipart(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.SuccessCount < 2 || resp.FailureCount != 0 {
+		t.Fatalf("expected several successful parts, got success=%d failure=%d", resp.SuccessCount, resp.FailureCount)
+	}
+
+	sent := srv.DeliveredTo("device")
+	// Parts are sent concurrently, so restore their order first.
+	sort.Slice(sent, func(i, j int) bool {
+		a, _ := strconv.Atoi(sent[i].Message.Data[fcm.MultipartIndexKey])
+		b, _ := strconv.Atoi(sent[j].Message.Data[fcm.MultipartIndexKey])
+		return a < b
+	})
+	clock := fcmtest.NewClock(time.Now())
+	r := fcm.NewReassembler(time.Minute, clock)
+	var (
+		got      map[string]string
+		complete bool
+	)
+	// Deliver the parts in reverse order.
+	for i := len(sent) - 1; i >= 0; i-- {
+		part := sent[i].Message
+		if part.Android.CollapseKey != "sync" {
+			t.Fatalf("part %d lost the collapse key", i)
+		}
+		if (part.Notification != nil) != (i == len(sent)-1) {
+			t.Fatalf("part %d: notification must only be on the last part", i)
+		}
+		aps := part.APNS.Payload.Aps
+		if (aps.AlertString != "" || aps.Sound != "") != (i == len(sent)-1) {
+			t.Fatalf("part %d: apns alert and sound must only be on the last part", i)
+		}
+		got, complete, err = r.Add(part.Data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if complete != (i == 0) {
+			t.Fatalf("part %d: complete = %v", i, complete)
+		}
+	}
+	if !reflect.DeepEqual(got, data) {
+		t.Fatal("reassembled data does not match the original")
+	}
+}
+
+func TestSplitDataWithoutRoomForData(t *testing.T) {
+	msg := &messaging.Message{
+		Token:        "device",
+		Data:         map[string]string{"blob": strings.Repeat("界", 2000)},
+		Notification: &messaging.Notification{Title: strings.Repeat("t", fcm.MaxPayloadSize)},
+	}
+	if _, err := fcm.SplitData(msg); err == nil {
+		t.Fatal("expected an error when the notification leaves no room for data")
+	}
+}
+
+func TestReassemblerTimeout(t *testing.T) {
+	msg := &messaging.Message{
+		Token: "device",
+		Data:  map[string]string{"blob": strings.Repeat("x", 9000)},
+	}
+	parts, err := fcm.SplitData(msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(parts) < 3 {
+		t.Fatalf("expected at least 3 parts, got %d", len(parts))
+	}
+
+	clock := fcmtest.NewClock(time.Now())
+	r := fcm.NewReassembler(time.Minute, clock)
+	if _, complete, err := r.Add(parts[0].Data); err != nil || complete {
+		t.Fatalf("unexpected result: complete=%v err=%v", complete, err)
+	}
+	clock.Advance(2 * time.Minute)
+	expired := r.Expire()
+	if len(expired) != 1 || expired[0] != parts[0].Data[fcm.MultipartIDKey] {
+		t.Fatalf("expected the payload to expire, got %v", expired)
+	}
+	if r.Pending() != 0 {
+		t.Fatalf("expected no pending payloads, got %d", r.Pending())
+	}
+
+	small := &messaging.Message{Token: "device", Data: map[string]string{"a": "b"}}
+	parts, err = fcm.SplitData(small)
+	if err != nil || len(parts) != 1 || parts[0] != small {
+		t.Fatalf("expected a fitting message to be returned unchanged, got %v %v", parts, err)
+	}
+	got, complete, err := r.Add(small.Data)
+	if err != nil || !complete || got["a"] != "b" {
+		t.Fatalf("expected plain data to pass through, got %v %v %v", got, complete, err)
+	}
+}
@@ -1,10 +1,12 @@
 package fcm
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 
 	"golang.org/x/oauth2"
 	"google.golang.org/api/option"
@@ -75,6 +77,25 @@ func WithEndpoint(endpoint string) Option {
 	}
 }
 
+// WithInstanceIDEndpoint returns Option to replace the Instance ID API
+// endpoint used by GetTokenInfo and ImportAPNsTokens, for instance to point
+// it at a local stand-in.
+func WithInstanceIDEndpoint(endpoint string) Option {
+	return func(c *Client) error {
+		c.iidEndpoint = strings.TrimSuffix(endpoint, "/")
+		return nil
+	}
+}
+
+// WithDeviceGroupEndpoint returns Option to replace the device group API
+// endpoint, for instance to point it at a local stand-in.
+func WithDeviceGroupEndpoint(endpoint string) Option {
+	return func(c *Client) error {
+		c.groupEndpoint = strings.TrimSuffix(endpoint, "/")
+		return nil
+	}
+}
+
 // WithServiceAccount returns Option to configure service account.
 func WithServiceAccount(serviceAccount string) Option {
 	return func(c *Client) error {
@@ -91,6 +112,16 @@ func WithProjectID(projectID string) Option {
 	}
 }
 
+// WithSenderID returns Option to configure the sender ID, the numeric project
+// number, that the device group API expects. The device group methods fail
+// without it, since the project ID is not accepted in its place.
+func WithSenderID(senderID string) Option {
+	return func(c *Client) error {
+		c.senderID = senderID
+		return nil
+	}
+}
+
 // WithTokenSource returns a ClientOption that specifies an OAuth2 token
 // source to be used as the basis for authentication.
 func WithTokenSource(s oauth2.TokenSource) Option {
@@ -109,6 +140,78 @@ func WithDebug(debug bool) Option {
 	}
 }
 
+// WithClock returns Option to configure the Clock used for every
+// time-dependent behavior of the Client. It defaults to the system clock.
+func WithClock(clock Clock) Option {
+	return func(c *Client) error {
+		if clock == nil {
+			return errors.New("clock must not be nil")
+		}
+		c.clock = clock
+		return nil
+	}
+}
+
+// WithPreflightValidation returns Option to run Validate (or
+// ValidateMulticast) locally on every message before Send, SendDryRun,
+// SendMulticast and SendMulticastDryRun contact FCM, so invalid messages are
+// rejected without a network round trip.
+func WithPreflightValidation(enabled bool) Option {
+	return func(c *Client) error {
+		c.preflight = enabled
+		return nil
+	}
+}
+
+// WithTokenStore returns Option to attach a TokenStore. Tokens that Send and
+// SendMulticast find FCM reporting as UNREGISTERED or INVALID_ARGUMENT are
+// then removed from the store when remove is true, or marked invalid
+// otherwise.
+func WithTokenStore(store TokenStore, remove bool) Option {
+	return func(c *Client) error {
+		if store == nil {
+			return errors.New("token store must not be nil")
+		}
+		c.tokenStore = store
+		c.removeInvalid = remove
+		return nil
+	}
+}
+
+// WithStalenessPolicy returns Option to apply p to the tokens of every
+// SendMulticast. It requires a TokenActivityStore attached with
+// WithTokenStore.
+func WithStalenessPolicy(p StalenessPolicy) Option {
+	return func(c *Client) error {
+		c.staleness = &p
+		return nil
+	}
+}
+
+// WithTokenDedup returns Option to trim the surrounding whitespace of the
+// tokens passed to SendMulticast, SubscribeTopic and UnsubscribeTopic and
+// send each distinct token once. Responses are still reported for every
+// input index, and tokens left empty by trimming fail on their own; see
+// DedupTokens to find the collapsed entries.
+func WithTokenDedup(enabled bool) Option {
+	return func(c *Client) error {
+		c.dedupTokens = enabled
+		return nil
+	}
+}
+
+// WithTopicIndex returns Option to record the results of SubscribeTopic and
+// UnsubscribeTopic in idx.
+func WithTopicIndex(idx *TopicIndex) Option {
+	return func(c *Client) error {
+		if idx == nil {
+			return errors.New("topic index must not be nil")
+		}
+		c.topicIndex = idx
+		return nil
+	}
+}
+
 // WithCustomClientOption is an option function that allows you to provide custom client options.
 // It appends the provided custom options to the client's options list.
 // The custom options are applied when sending requests to the FCM server.
@@ -51,3 +51,17 @@ func TestWithCustomClientOptionAppends(t *testing.T) {
 		t.Fatalf("expected 1 option appended, got %d", len(c.options))
 	}
 }
+
+func TestWithClock(t *testing.T) {
+	c := &Client{}
+	if err := WithClock(nil)(c); err == nil {
+		t.Fatal("expected error for nil clock, got nil")
+	}
+	clock := realClock{}
+	if err := WithClock(clock)(c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.clock != clock {
+		t.Fatalf("expected clock to be set, got %v", c.clock)
+	}
+}
@@ -0,0 +1,155 @@
+package fcm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// APNs header values used by the presets and checked by ValidatePlatforms.
+const (
+	apnsPriorityImmediate  = "10"
+	apnsPriorityConserve   = "5"
+	apnsPushTypeAlert      = "alert"
+	apnsPushTypeBackground = "background"
+)
+
+// SilentDataPush returns a message that delivers data to the app without
+// showing anything: Android normal priority, APNs content-available with
+// apns-priority 5 and apns-push-type background and no alert, sound or badge,
+// and Webpush normal urgency. Set the target before sending.
+func SilentDataPush(data map[string]string) *messaging.Message {
+	return &messaging.Message{
+		Data: data,
+		Android: &messaging.AndroidConfig{
+			Priority: "normal",
+		},
+		APNS: &messaging.APNSConfig{
+			Headers: map[string]string{
+				"apns-priority":  apnsPriorityConserve,
+				"apns-push-type": apnsPushTypeBackground,
+			},
+			Payload: &messaging.APNSPayload{
+				Aps: &messaging.Aps{ContentAvailable: true},
+			},
+		},
+		Webpush: &messaging.WebpushConfig{
+			Headers: map[string]string{"Urgency": "normal"},
+		},
+	}
+}
+
+// HighPriorityAlert returns a visible notification delivered immediately on
+// every platform: Android high priority, APNs apns-priority 10 with
+// apns-push-type alert and the default sound, and Webpush high urgency. Set
+// the target before sending.
+func HighPriorityAlert(title, body string) *messaging.Message {
+	return &messaging.Message{
+		Notification: &messaging.Notification{Title: title, Body: body},
+		Android: &messaging.AndroidConfig{
+			Priority: "high",
+			Notification: &messaging.AndroidNotification{
+				Priority: messaging.PriorityHigh,
+			},
+		},
+		APNS: &messaging.APNSConfig{
+			Headers: map[string]string{
+				"apns-priority":  apnsPriorityImmediate,
+				"apns-push-type": apnsPushTypeAlert,
+			},
+			Payload: &messaging.APNSPayload{
+				Aps: &messaging.Aps{
+					Alert: &messaging.ApsAlert{Title: title, Body: body},
+					Sound: "default",
+				},
+			},
+		},
+		Webpush: &messaging.WebpushConfig{
+			Headers: map[string]string{"Urgency": "high"},
+		},
+	}
+}
+
+// TimeSensitiveAlert is HighPriorityAlert with the iOS "time-sensitive"
+// interruption level, which breaks through Focus modes, and the Android
+// maximum notification priority.
+func TimeSensitiveAlert(title, body string) *messaging.Message {
+	msg := HighPriorityAlert(title, body)
+	msg.Android.Notification.Priority = messaging.PriorityMax
+	msg.APNS.Payload.Aps.CustomData = map[string]any{
+		"interruption-level": "time-sensitive",
+	}
+	return msg
+}
+
+// ValidatePlatforms checks the Android, APNs and Webpush settings of a
+// message for combinations the platforms reject or silently ignore, such as
+// a background APNs push with an alert, or with apns-priority 10, and the
+// expiry and collapse settings against each platform's limits.
+func ValidatePlatforms(message *messaging.Message) error {
+	var errs []error
+	if a := message.Android; a != nil && a.Priority != "" && a.Priority != "normal" && a.Priority != "high" {
+		errs = append(errs, fmt.Errorf("android: priority must be \"normal\" or \"high\", got %q", a.Priority))
+	}
+	if w := message.Webpush; w != nil {
+		switch u := w.Headers["Urgency"]; u {
+		case "", "very-low", "low", "normal", "high":
+		default:
+			errs = append(errs, fmt.Errorf("webpush: unknown Urgency %q", u))
+		}
+	}
+	if message.APNS != nil {
+		errs = append(errs, validateAPNS(message.APNS, message.Notification != nil))
+	}
+	errs = append(errs, validateExpiry(message))
+	return errors.Join(errs...)
+}
+
+func validateAPNS(apns *messaging.APNSConfig, hasNotification bool) error {
+	var errs []error
+	priority := apns.Headers["apns-priority"]
+	pushType := apns.Headers["apns-push-type"]
+	if priority != "" && priority != apnsPriorityImmediate && priority != apnsPriorityConserve && priority != "1" {
+		errs = append(errs, fmt.Errorf("apns: apns-priority must be 1, 5 or 10, got %q", priority))
+	}
+
+	var aps *messaging.Aps
+	if apns.Payload != nil {
+		aps = apns.Payload.Aps
+	}
+	visible := hasNotification
+	if aps != nil {
+		visible = visible || aps.Alert != nil || aps.AlertString != "" ||
+			aps.Sound != "" || aps.CriticalSound != nil || aps.Badge != nil
+	}
+
+	if pushType == apnsPushTypeBackground {
+		if priority == apnsPriorityImmediate {
+			errs = append(errs, errors.New("apns: background pushes must not use apns-priority 10"))
+		}
+		if aps == nil || !aps.ContentAvailable {
+			errs = append(errs, errors.New("apns: background pushes require content-available"))
+		}
+		if visible {
+			errs = append(errs, errors.New("apns: background pushes must not carry an alert, sound or badge"))
+		}
+	}
+	if pushType == apnsPushTypeAlert && !visible {
+		errs = append(errs, errors.New("apns: alert pushes require an alert, sound or badge"))
+	}
+
+	if aps != nil {
+		level, _ := aps.CustomData["interruption-level"].(string)
+		if strings.EqualFold(level, "time-sensitive") || strings.EqualFold(level, "critical") {
+			if !visible {
+				errs = append(errs, fmt.Errorf("apns: interruption-level %q requires a visible notification", level))
+			}
+			if pushType == apnsPushTypeBackground {
+				errs = append(errs, fmt.Errorf("apns: interruption-level %q conflicts with apns-push-type background", level))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
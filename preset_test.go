@@ -0,0 +1,81 @@
+package fcm
+
+import (
+	"strings"
+	"testing"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+func TestPresetsAreConsistent(t *testing.T) {
+	presets := map[string]*messaging.Message{
+		"silent":         SilentDataPush(map[string]string{"sync": "1"}),
+		"high priority":  HighPriorityAlert("title", "body"),
+		"time sensitive": TimeSensitiveAlert("title", "body"),
+	}
+	for name, msg := range presets {
+		msg.Token = "test"
+		if err := Validate(msg); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+
+	silent := presets["silent"]
+	if silent.Notification != nil || silent.APNS.Headers["apns-priority"] != "5" || !silent.APNS.Payload.Aps.ContentAvailable {
+		t.Fatalf("unexpected silent preset: %+v", silent.APNS)
+	}
+	if presets["time sensitive"].APNS.Payload.Aps.CustomData["interruption-level"] != "time-sensitive" {
+		t.Fatal("time sensitive preset lacks interruption-level")
+	}
+}
+
+func TestValidatePlatformsConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(m *messaging.Message)
+		want   string
+	}{
+		{
+			name:   "silent with alert",
+			mutate: func(m *messaging.Message) { m.APNS.Payload.Aps.Sound = "default" },
+			want:   "must not carry an alert",
+		},
+		{
+			name:   "silent with priority 10",
+			mutate: func(m *messaging.Message) { m.APNS.Headers["apns-priority"] = "10" },
+			want:   "must not use apns-priority 10",
+		},
+		{
+			name:   "silent without content-available",
+			mutate: func(m *messaging.Message) { m.APNS.Payload.Aps.ContentAvailable = false },
+			want:   "require content-available",
+		},
+		{
+			name:   "silent with notification",
+			mutate: func(m *messaging.Message) { m.Notification = &messaging.Notification{Title: "x"} },
+			want:   "must not carry an alert",
+		},
+		{
+			name:   "bad android priority",
+			mutate: func(m *messaging.Message) { m.Android.Priority = "urgent" },
+			want:   "android: priority",
+		},
+		{
+			name: "time-sensitive background",
+			mutate: func(m *messaging.Message) {
+				m.APNS.Payload.Aps.CustomData = map[string]any{"interruption-level": "time-sensitive"}
+			},
+			want: "conflicts with apns-push-type background",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := SilentDataPush(map[string]string{"sync": "1"})
+			tt.mutate(msg)
+			err := ValidatePlatforms(msg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package fcm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// maxTopicBatchSize is the largest number of tokens the Instance ID API
+// accepts in a single batchAdd or batchRemove call.
+const maxTopicBatchSize = 1000
+
+// TopicMembershipSource reports the topics a registration token is currently
+// subscribed to, for ReconcileTopics to diff against.
+type TopicMembershipSource interface {
+	Topics(ctx context.Context, token string) ([]string, error)
+}
+
+// TopicDiff is the change of topic membership planned for one token.
+type TopicDiff struct {
+	Token       string
+	Subscribe   []string
+	Unsubscribe []string
+	// Failed maps the topics whose change could not be applied to the reason,
+	// once the plan has been applied.
+	Failed map[string]error
+}
+
+// TopicPlan is the set of topic membership changes that makes the current
+// memberships match the desired ones.
+type TopicPlan struct {
+	// Diffs holds the tokens with at least one change, sorted by token.
+	Diffs []TopicDiff
+}
+
+// String lists the planned changes, one "+ token topic" or "- token topic"
+// line per subscription to add or remove.
+func (p *TopicPlan) String() string {
+	var b strings.Builder
+	for _, d := range p.Diffs {
+		for _, topic := range d.Subscribe {
+			fmt.Fprintf(&b, "+ %s %s\n", d.Token, topic)
+		}
+		for _, topic := range d.Unsubscribe {
+			fmt.Fprintf(&b, "- %s %s\n", d.Token, topic)
+		}
+	}
+	return b.String()
+}
+
+// Failed returns the diffs with at least one failed change.
+func (p *TopicPlan) Failed() []TopicDiff {
+	var failed []TopicDiff
+	for _, d := range p.Diffs {
+		if len(d.Failed) > 0 {
+			failed = append(failed, d)
+		}
+	}
+	return failed
+}
+
+// PlanTopics computes the changes that bring every token of desired to
+// exactly its desired topics, as reported by current, without applying them.
+// A token mapped to no topics is unsubscribed from all of them; tokens absent
+// from desired are left alone. Topics may carry the "/topics/" prefix.
+func PlanTopics(
+	ctx context.Context,
+	desired map[string][]string,
+	current TopicMembershipSource,
+) (*TopicPlan, error) {
+	tokens := make([]string, 0, len(desired))
+	for token, topics := range desired {
+		for _, topic := range topics {
+			if err := ValidateTopic(topic); err != nil {
+				return nil, fmt.Errorf("invalid topic for token %s: %w", token, err)
+			}
+		}
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	plan := &TopicPlan{}
+	for _, token := range tokens {
+		have, err := current.Topics(ctx, token)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get topics of token %s: %w", token, err)
+		}
+		want := normalizeTopics(desired[token])
+		got := normalizeTopics(have)
+
+		d := TopicDiff{Token: token}
+		for _, topic := range want {
+			if _, ok := slices.BinarySearch(got, topic); !ok {
+				d.Subscribe = append(d.Subscribe, topic)
+			}
+		}
+		for _, topic := range got {
+			if _, ok := slices.BinarySearch(want, topic); !ok {
+				d.Unsubscribe = append(d.Unsubscribe, topic)
+			}
+		}
+		if len(d.Subscribe) > 0 || len(d.Unsubscribe) > 0 {
+			plan.Diffs = append(plan.Diffs, d)
+		}
+	}
+	return plan, nil
+}
+
+// normalizeTopics returns topics without prefixes or duplicates, sorted.
+func normalizeTopics(topics []string) []string {
+	out := make([]string, len(topics))
+	for i, topic := range topics {
+		out[i] = NormalizeTopic(topic)
+	}
+	sort.Strings(out)
+	return slices.Compact(out)
+}
+
+// ApplyTopicPlan executes plan with one SubscribeTopic or UnsubscribeTopic
+// call per topic and batch of up to 1000 tokens, recording the changes FCM
+// rejects in the Failed field of each diff. The returned error joins the
+// calls that failed as a whole; their tokens are recorded as failed too.
+func (c *Client) ApplyTopicPlan(ctx context.Context, plan *TopicPlan) error {
+	subscribe := map[string][]int{}
+	unsubscribe := map[string][]int{}
+	for i, d := range plan.Diffs {
+		for _, topic := range d.Subscribe {
+			subscribe[topic] = append(subscribe[topic], i)
+		}
+		for _, topic := range d.Unsubscribe {
+			unsubscribe[topic] = append(unsubscribe[topic], i)
+		}
+	}
+
+	var errs []error
+	for _, topic := range sortedTopics(subscribe) {
+		errs = append(errs, c.applyTopicChange(ctx, plan, topic, subscribe[topic], c.SubscribeTopic))
+	}
+	for _, topic := range sortedTopics(unsubscribe) {
+		errs = append(errs, c.applyTopicChange(ctx, plan, topic, unsubscribe[topic], c.UnsubscribeTopic))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Client) applyTopicChange(
+	ctx context.Context,
+	plan *TopicPlan,
+	topic string,
+	diffs []int,
+	call func(context.Context, []string, string) (*messaging.TopicManagementResponse, error),
+) error {
+	fail := func(i int, err error) {
+		d := &plan.Diffs[i]
+		if d.Failed == nil {
+			d.Failed = map[string]error{}
+		}
+		d.Failed[topic] = err
+	}
+
+	var errs []error
+	for start := 0; start < len(diffs); start += maxTopicBatchSize {
+		batch := diffs[start:min(start+maxTopicBatchSize, len(diffs))]
+		tokens := make([]string, len(batch))
+		for j, i := range batch {
+			tokens[j] = plan.Diffs[i].Token
+		}
+		resp, err := call(ctx, tokens, topic)
+		if err != nil {
+			for _, i := range batch {
+				fail(i, err)
+			}
+			errs = append(errs, fmt.Errorf("cannot change topic %s for %d tokens: %w", topic, len(tokens), err))
+			continue
+		}
+		for _, e := range resp.Errors {
+			if e.Index >= 0 && e.Index < len(batch) {
+				fail(batch[e.Index], errors.New(e.Reason))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func sortedTopics(m map[string][]int) []string {
+	topics := make([]string, 0, len(m))
+	for topic := range m {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// ReconcileTopics makes the topic memberships of the tokens in desired match
+// it: it plans the changes with PlanTopics and applies them with
+// ApplyTopicPlan, returning the plan with the failed changes recorded. Call
+// PlanTopics alone for a dry run.
+func (c *Client) ReconcileTopics(
+	ctx context.Context,
+	desired map[string][]string,
+	current TopicMembershipSource,
+) (*TopicPlan, error) {
+	plan, err := PlanTopics(ctx, desired, current)
+	if err != nil {
+		return nil, err
+	}
+	return plan, c.ApplyTopicPlan(ctx, plan)
+}
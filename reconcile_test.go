@@ -0,0 +1,72 @@
+package fcm_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+)
+
+// serverTopics reads the current memberships from the fake server.
+type serverTopics struct{ srv *fcmtest.Server }
+
+func (s serverTopics) Topics(_ context.Context, token string) ([]string, error) {
+	return s.srv.Subscriptions(token), nil
+}
+
+func TestReconcileTopics(t *testing.T) {
+	ctx := context.Background()
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+	srv.Subscribe("en", "t1", "t2")
+	srv.Subscribe("football", "t1")
+	srv.Subscribe("fr", "t3")
+	srv.FailToken("gone", "UNREGISTERED")
+
+	desired := map[string][]string{
+		"t1":   {"en", "/topics/tennis", "tennis"},
+		"t2":   {"en"},
+		"t3":   {},
+		"gone": {"en"},
+	}
+
+	plan, err := fcm.PlanTopics(ctx, desired, serverTopics{srv})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "+ gone en\n+ t1 tennis\n- t1 football\n- t3 fr\n"
+	if plan.String() != want {
+		t.Fatalf("plan =\n%s\nwant\n%s", plan, want)
+	}
+	if got := srv.Subscriptions("t1"); !reflect.DeepEqual(got, []string{"en", "football"}) {
+		t.Fatalf("planning must not change memberships, got %v", got)
+	}
+
+	client, err := fcm.NewClient(ctx, fcm.WithProjectID("test"), fcm.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plan, err = client.ReconcileTopics(ctx, desired, serverTopics{srv})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	failed := plan.Failed()
+	if len(failed) != 1 || failed[0].Token != "gone" || failed[0].Failed["en"] == nil {
+		t.Fatalf("unexpected failures: %+v", failed)
+	}
+	for token, topics := range map[string][]string{"t1": {"en", "tennis"}, "t2": {"en"}, "t3": {}} {
+		if got := srv.Subscriptions(token); len(got)+len(topics) > 0 && !reflect.DeepEqual(got, topics) {
+			t.Fatalf("Subscriptions(%s) = %v, want %v", token, got, topics)
+		}
+	}
+
+	plan, err = fcm.PlanTopics(ctx, map[string][]string{"t1": {"en", "tennis"}}, serverTopics{srv})
+	if err != nil || len(plan.Diffs) != 0 {
+		t.Fatalf("expected an empty plan once reconciled, got %v %v", plan, err)
+	}
+	if _, err := fcm.PlanTopics(ctx, map[string][]string{"t1": {"bad topic"}}, serverTopics{srv}); err == nil {
+		t.Fatal("expected error for an invalid topic, got nil")
+	}
+}
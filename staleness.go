@@ -0,0 +1,172 @@
+package fcm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// DefaultStaleAfter is the inactivity after which FCM recommends treating a
+// registration token as stale.
+const DefaultStaleAfter = 270 * 24 * time.Hour
+
+// StaleAction selects what SendMulticast does with stale tokens.
+type StaleAction int
+
+const (
+	// StaleWarn sends to stale tokens and only reports them to OnStale.
+	StaleWarn StaleAction = iota
+	// StaleExclude reports stale tokens to OnStale and does not send to them.
+	// Their responses fail with a *StaleTokenError.
+	StaleExclude
+)
+
+// StalenessPolicy decides which tokens of the attached TokenActivityStore are
+// stale. Tokens unknown to the store are never stale.
+type StalenessPolicy struct {
+	// MaxAge is the inactivity after which a token is stale. Zero means
+	// DefaultStaleAfter.
+	MaxAge time.Duration
+	// Action is applied to stale tokens before SendMulticast.
+	Action StaleAction
+	// OnStale, if set, is called with the stale tokens found before each
+	// SendMulticast.
+	OnStale func(stale []StaleToken)
+}
+
+// StaleToken is a multicast token found stale by a StalenessPolicy.
+type StaleToken struct {
+	// Index is the position of the token in MulticastMessage.Tokens.
+	Index  int
+	Record TokenRecord
+}
+
+// StaleTokenError is the error of a response for a token excluded by a
+// StalenessPolicy.
+type StaleTokenError struct {
+	Token    string
+	LastSeen time.Time
+}
+
+func (e *StaleTokenError) Error() string {
+	return fmt.Sprintf("token %s is stale: last seen %s", e.Token, e.LastSeen.Format(time.RFC3339))
+}
+
+func (p StalenessPolicy) maxAge() time.Duration {
+	if p.MaxAge > 0 {
+		return p.MaxAge
+	}
+	return DefaultStaleAfter
+}
+
+// IsStale reports whether r has been inactive for longer than the policy
+// allows at now.
+func (p StalenessPolicy) IsStale(r TokenRecord, now time.Time) bool {
+	return now.Sub(r.LastSeen()) > p.maxAge()
+}
+
+// SweepTokens returns the records of store that are candidates for cleanup
+// at now: tokens already marked invalid and tokens p finds stale.
+func SweepTokens(ctx context.Context, store TokenActivityStore, p StalenessPolicy, now time.Time) ([]TokenRecord, error) {
+	records, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []TokenRecord
+	for _, r := range records {
+		if r.Invalid != "" || p.IsStale(r, now) {
+			candidates = append(candidates, r)
+		}
+	}
+	return candidates, nil
+}
+
+// SweepTokens is SweepTokens for the Client's TokenStore, StalenessPolicy
+// (or the default one) and Clock.
+func (c *Client) SweepTokens(ctx context.Context) ([]TokenRecord, error) {
+	store, ok := c.tokenStore.(TokenActivityStore)
+	if !ok {
+		return nil, errors.New("client has no TokenActivityStore attached")
+	}
+	var p StalenessPolicy
+	if c.staleness != nil {
+		p = *c.staleness
+	}
+	return SweepTokens(ctx, store, p, c.clock.Now())
+}
+
+// applyStaleness finds the stale tokens of message and, depending on the
+// policy, returns the message to send instead together with the responses of
+// the excluded tokens, indexed like message.Tokens. The returned message is
+// nil when every token was excluded.
+func (c *Client) applyStaleness(
+	ctx context.Context,
+	message *messaging.MulticastMessage,
+) (*messaging.MulticastMessage, []*messaging.SendResponse, error) {
+	store, ok := c.tokenStore.(TokenActivityStore)
+	if c.staleness == nil || !ok {
+		return message, nil, nil
+	}
+	records, err := store.Lookup(ctx, message.Tokens)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot look up tokens: %w", err)
+	}
+
+	now := c.clock.Now()
+	var stale []StaleToken
+	for i, token := range message.Tokens {
+		if r, ok := records[token]; ok && c.staleness.IsStale(r, now) {
+			stale = append(stale, StaleToken{Index: i, Record: r})
+		}
+	}
+	if len(stale) == 0 {
+		return message, nil, nil
+	}
+	if c.staleness.OnStale != nil {
+		c.staleness.OnStale(stale)
+	}
+	if c.staleness.Action != StaleExclude {
+		return message, nil, nil
+	}
+
+	excluded := make([]*messaging.SendResponse, len(message.Tokens))
+	for _, s := range stale {
+		excluded[s.Index] = &messaging.SendResponse{
+			Error: &StaleTokenError{Token: s.Record.Token, LastSeen: s.Record.LastSeen()},
+		}
+	}
+	kept := *message
+	kept.Tokens = make([]string, 0, len(message.Tokens)-len(stale))
+	for i, token := range message.Tokens {
+		if excluded[i] == nil {
+			kept.Tokens = append(kept.Tokens, token)
+		}
+	}
+	if len(kept.Tokens) == 0 {
+		return nil, excluded, nil
+	}
+	return &kept, excluded, nil
+}
+
+// mergeExcluded interleaves the responses of the tokens sent in resp with
+// the excluded ones, so the result is indexed like the original tokens.
+func mergeExcluded(resp *messaging.BatchResponse, excluded []*messaging.SendResponse) *messaging.BatchResponse {
+	merged := &messaging.BatchResponse{Responses: make([]*messaging.SendResponse, len(excluded))}
+	next := 0
+	for i, r := range excluded {
+		if r == nil && resp != nil && next < len(resp.Responses) {
+			r = resp.Responses[next]
+			next++
+		}
+		merged.Responses[i] = r
+		if r != nil && r.Success {
+			merged.SuccessCount++
+		} else {
+			merged.FailureCount++
+		}
+	}
+	return merged
+}
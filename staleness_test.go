@@ -0,0 +1,128 @@
+package fcm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+)
+
+func TestStalenessPolicy(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+
+	clock := fcmtest.NewClock(now.Add(-400 * 24 * time.Hour))
+	store := fcm.NewMemoryTokenStore(clock)
+	_ = store.Add(ctx, "alice", "fresh")
+	clock.Set(now.Add(-300 * 24 * time.Hour))
+	_ = store.Add(ctx, "alice", "old")
+	clock.Set(now.Add(-time.Hour))
+	_ = store.Add(ctx, "alice", "fresh")
+	clock.Set(now)
+	_ = store.Add(ctx, "alice", "dead")
+	_ = store.MarkInvalid(ctx, "dead", fcm.ReasonUnregistered)
+
+	var reported []fcm.StaleToken
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+		fcm.WithClock(clock),
+		fcm.WithTokenStore(store, false),
+		fcm.WithStalenessPolicy(fcm.StalenessPolicy{
+			Action:  fcm.StaleExclude,
+			OnStale: func(stale []fcm.StaleToken) { reported = stale },
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := client.SendMulticast(ctx, &messaging.MulticastMessage{
+		Tokens: []string{"old", "fresh", "unknown"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Responses) != 3 || resp.SuccessCount != 2 || resp.FailureCount != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	var staleErr *fcm.StaleTokenError
+	if !errors.As(resp.Responses[0].Error, &staleErr) || staleErr.Token != "old" {
+		t.Fatalf("expected a StaleTokenError at index 0, got %v", resp.Responses[0].Error)
+	}
+	if len(reported) != 1 || reported[0].Index != 0 {
+		t.Fatalf("unexpected stale report: %+v", reported)
+	}
+	if len(srv.DeliveredTo("old")) != 0 || len(srv.DeliveredTo("fresh")) != 1 {
+		t.Fatal("expected only the fresh tokens to be sent to")
+	}
+
+	resp, err = client.SendMulticast(ctx, &messaging.MulticastMessage{Tokens: []string{"old"}})
+	if err != nil || resp.FailureCount != 1 {
+		t.Fatalf("expected the only token to be excluded, got %+v %v", resp, err)
+	}
+
+	candidates, err := client.SweepTokens(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].Token != "dead" || candidates[1].Token != "old" {
+		t.Fatalf("unexpected sweep candidates: %+v", candidates)
+	}
+
+	if _, err := fcm.NewClient(ctx, fcm.WithStalenessPolicy(fcm.StalenessPolicy{})); err == nil {
+		t.Fatal("expected error without a TokenActivityStore, got nil")
+	}
+}
+
+func TestStalenessPolicyWarn(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+
+	clock := fcmtest.NewClock(now.Add(-300 * 24 * time.Hour))
+	store := fcm.NewMemoryTokenStore(clock)
+	_ = store.Add(ctx, "alice", "old")
+	clock.Set(now)
+
+	var reports int
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+		fcm.WithClock(clock),
+		fcm.WithTokenStore(store, false),
+		fcm.WithStalenessPolicy(fcm.StalenessPolicy{
+			OnStale: func([]fcm.StaleToken) { reports++ },
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Sends accepted by FCM must not make the stale token fresh again.
+	for i := 0; i < 2; i++ {
+		resp, err := client.SendMulticast(ctx, &messaging.MulticastMessage{Tokens: []string{"old"}})
+		if err != nil || resp.SuccessCount != 1 {
+			t.Fatalf("expected the stale token to be sent to, got %+v %v", resp, err)
+		}
+	}
+	if reports != 2 {
+		t.Fatalf("expected OnStale on every send, got %d reports", reports)
+	}
+	candidates, err := client.SweepTokens(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Token != "old" || candidates[0].LastSentAt.IsZero() {
+		t.Fatalf("unexpected sweep candidates: %+v", candidates)
+	}
+}
@@ -0,0 +1,69 @@
+package fcm
+
+import (
+	"context"
+	"fmt"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/appleboy/go-fcm/template"
+)
+
+// RenderError reports a recipient whose message could not be rendered from a
+// template and was therefore not sent.
+type RenderError struct {
+	Index int
+	Token string
+	Err   error
+}
+
+func (e *RenderError) Error() string {
+	return fmt.Sprintf("cannot render message for recipient %d: %v", e.Index, e.Err)
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// SendTemplate renders tmpl for every recipient and sends the results through
+// Send, so large recipient lists are chunked automatically. The returned
+// BatchResponse has one entry per recipient, in input order; recipients whose
+// message could not be rendered are reported as failures with a *RenderError
+// and are not sent. If Send fails part way, the entries of recipients that were
+// not attempted are nil.
+func (c *Client) SendTemplate(
+	ctx context.Context,
+	tmpl *template.Template,
+	recipients []template.Recipient,
+) (*messaging.BatchResponse, error) {
+	out := &messaging.BatchResponse{
+		Responses: make([]*messaging.SendResponse, len(recipients)),
+	}
+
+	messages := make([]*messaging.Message, 0, len(recipients))
+	indexes := make([]int, 0, len(recipients))
+	for i, r := range recipients {
+		msg, err := tmpl.RenderTo(r)
+		if err != nil {
+			out.Responses[i] = &messaging.SendResponse{
+				Error: &RenderError{Index: i, Token: r.Token, Err: err},
+			}
+			out.FailureCount++
+			continue
+		}
+		messages = append(messages, msg)
+		indexes = append(indexes, i)
+	}
+	if len(messages) == 0 {
+		return out, nil
+	}
+
+	resp, err := c.Send(ctx, messages...)
+	if resp != nil {
+		for j, r := range resp.Responses {
+			out.Responses[indexes[j]] = r
+		}
+		out.SuccessCount += resp.SuccessCount
+		out.FailureCount += resp.FailureCount
+	}
+	return out, err
+}
@@ -0,0 +1,325 @@
+// Package template renders a messaging.Message per recipient from a shared
+// shape whose text fields are text/template templates.
+//
+//	tmpl, err := template.New("order-shipped", &messaging.Message{
+//		Notification: &messaging.Notification{
+//			Title: "Hi {{.Name}}",
+//			Body:  "Your order of {{.Amount}} has shipped",
+//		},
+//		Data: map[string]string{"link": "myapp://orders/{{.OrderID}}"},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	resp, err := client.SendTemplate(ctx, tmpl, []template.Recipient{
+//		{Token: token, Vars: map[string]any{"Name": "Ada", "Amount": "$12", "OrderID": 42}},
+//	})
+//
+// Templated fields are the notification title, body and image on every
+// platform, data values (including Android data), the Android click action,
+// the APNs alert and the Webpush link. Missing variables are an error.
+package template
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// Recipient is one target of a templated send: a registration token and the
+// variables its message is rendered with.
+type Recipient struct {
+	Token string
+	Vars  any
+}
+
+// Template is a parsed message template. It is safe for concurrent use.
+type Template struct {
+	name        string
+	proto       *messaging.Message
+	fields      []compiledField
+	data        map[string]*texttemplate.Template
+	androidData map[string]*texttemplate.Template
+}
+
+type compiledField struct {
+	field
+	tmpl *texttemplate.Template
+}
+
+// field locates a templatable string in a message. ptr returns nil when the
+// enclosing struct is not set.
+type field struct {
+	name string
+	ptr  func(m *messaging.Message) *string
+}
+
+var fields = []field{
+	{"notification.title", func(m *messaging.Message) *string {
+		if m.Notification == nil {
+			return nil
+		}
+		return &m.Notification.Title
+	}},
+	{"notification.body", func(m *messaging.Message) *string {
+		if m.Notification == nil {
+			return nil
+		}
+		return &m.Notification.Body
+	}},
+	{"notification.image", func(m *messaging.Message) *string {
+		if m.Notification == nil {
+			return nil
+		}
+		return &m.Notification.ImageURL
+	}},
+	{"android.notification.title", func(m *messaging.Message) *string {
+		if m.Android == nil || m.Android.Notification == nil {
+			return nil
+		}
+		return &m.Android.Notification.Title
+	}},
+	{"android.notification.body", func(m *messaging.Message) *string {
+		if m.Android == nil || m.Android.Notification == nil {
+			return nil
+		}
+		return &m.Android.Notification.Body
+	}},
+	{"android.notification.click_action", func(m *messaging.Message) *string {
+		if m.Android == nil || m.Android.Notification == nil {
+			return nil
+		}
+		return &m.Android.Notification.ClickAction
+	}},
+	{"apns.payload.aps.alert", func(m *messaging.Message) *string {
+		if m.APNS == nil || m.APNS.Payload == nil || m.APNS.Payload.Aps == nil {
+			return nil
+		}
+		return &m.APNS.Payload.Aps.AlertString
+	}},
+	{"apns.payload.aps.alert.title", func(m *messaging.Message) *string {
+		if m.APNS == nil || m.APNS.Payload == nil || m.APNS.Payload.Aps == nil || m.APNS.Payload.Aps.Alert == nil {
+			return nil
+		}
+		return &m.APNS.Payload.Aps.Alert.Title
+	}},
+	{"apns.payload.aps.alert.body", func(m *messaging.Message) *string {
+		if m.APNS == nil || m.APNS.Payload == nil || m.APNS.Payload.Aps == nil || m.APNS.Payload.Aps.Alert == nil {
+			return nil
+		}
+		return &m.APNS.Payload.Aps.Alert.Body
+	}},
+	{"webpush.notification.title", func(m *messaging.Message) *string {
+		if m.Webpush == nil || m.Webpush.Notification == nil {
+			return nil
+		}
+		return &m.Webpush.Notification.Title
+	}},
+	{"webpush.notification.body", func(m *messaging.Message) *string {
+		if m.Webpush == nil || m.Webpush.Notification == nil {
+			return nil
+		}
+		return &m.Webpush.Notification.Body
+	}},
+	{"webpush.fcm_options.link", func(m *messaging.Message) *string {
+		if m.Webpush == nil || m.Webpush.FCMOptions == nil {
+			return nil
+		}
+		return &m.Webpush.FCMOptions.Link
+	}},
+}
+
+// New parses the templatable fields of msg. The target fields of msg are
+// ignored; Render leaves them empty for the caller to fill in.
+func New(name string, msg *messaging.Message) (*Template, error) {
+	if msg == nil {
+		return nil, errors.New("template message must not be nil")
+	}
+	t := &Template{name: name, proto: msg}
+
+	parse := func(fieldName, text string) (*texttemplate.Template, error) {
+		tmpl, err := texttemplate.New(name + ":" + fieldName).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("template %q: field %s: %w", name, fieldName, err)
+		}
+		return tmpl, nil
+	}
+
+	for _, f := range fields {
+		p := f.ptr(msg)
+		if p == nil || !strings.Contains(*p, "{{") {
+			continue
+		}
+		tmpl, err := parse(f.name, *p)
+		if err != nil {
+			return nil, err
+		}
+		t.fields = append(t.fields, compiledField{field: f, tmpl: tmpl})
+	}
+
+	var err error
+	if t.data, err = parseData(msg.Data, "data.", parse); err != nil {
+		return nil, err
+	}
+	if msg.Android != nil {
+		if t.androidData, err = parseData(msg.Android.Data, "android.data.", parse); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Must is a helper that wraps a call to New and panics if the error is
+// non-nil.
+func Must(t *Template, err error) *Template {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func parseData(
+	data map[string]string,
+	prefix string,
+	parse func(string, string) (*texttemplate.Template, error),
+) (map[string]*texttemplate.Template, error) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out map[string]*texttemplate.Template
+	for _, k := range keys {
+		if !strings.Contains(data[k], "{{") {
+			continue
+		}
+		tmpl, err := parse(prefix+k, data[k])
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			out = map[string]*texttemplate.Template{}
+		}
+		out[k] = tmpl
+	}
+	return out, nil
+}
+
+// Name returns the name the template was created with.
+func (t *Template) Name() string {
+	return t.name
+}
+
+// Render returns a new message with every templated field executed against
+// vars. The template's own message is never modified, and the result has no
+// target set.
+func (t *Template) Render(vars any) (*messaging.Message, error) {
+	m := clone(t.proto)
+	var sb strings.Builder
+	exec := func(name string, tmpl *texttemplate.Template) (string, error) {
+		sb.Reset()
+		if err := tmpl.Execute(&sb, vars); err != nil {
+			return "", fmt.Errorf("template %q: field %s: %w", t.name, name, err)
+		}
+		return sb.String(), nil
+	}
+
+	for _, f := range t.fields {
+		out, err := exec(f.name, f.tmpl)
+		if err != nil {
+			return nil, err
+		}
+		*f.ptr(m) = out
+	}
+	for k, tmpl := range t.data {
+		out, err := exec("data."+k, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		m.Data[k] = out
+	}
+	for k, tmpl := range t.androidData {
+		out, err := exec("android.data."+k, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		m.Android.Data[k] = out
+	}
+	return m, nil
+}
+
+// RenderTo renders the template for r and addresses the result to r.Token.
+func (t *Template) RenderTo(r Recipient) (*messaging.Message, error) {
+	m, err := t.Render(r.Vars)
+	if err != nil {
+		return nil, err
+	}
+	m.Token = r.Token
+	return m, nil
+}
+
+// clone copies every level of msg that Render writes to, so rendered messages
+// never share mutable state with the template or with each other.
+func clone(msg *messaging.Message) *messaging.Message {
+	m := *msg
+	m.Token, m.Topic, m.Condition = "", "", ""
+	m.Data = cloneMap(msg.Data)
+	if msg.Notification != nil {
+		n := *msg.Notification
+		m.Notification = &n
+	}
+	if msg.Android != nil {
+		a := *msg.Android
+		a.Data = cloneMap(msg.Android.Data)
+		if a.Notification != nil {
+			n := *a.Notification
+			a.Notification = &n
+		}
+		m.Android = &a
+	}
+	if msg.APNS != nil {
+		apns := *msg.APNS
+		if apns.Payload != nil {
+			p := *apns.Payload
+			if p.Aps != nil {
+				aps := *p.Aps
+				if aps.Alert != nil {
+					alert := *aps.Alert
+					aps.Alert = &alert
+				}
+				p.Aps = &aps
+			}
+			apns.Payload = &p
+		}
+		m.APNS = &apns
+	}
+	if msg.Webpush != nil {
+		w := *msg.Webpush
+		if w.Notification != nil {
+			n := *w.Notification
+			w.Notification = &n
+		}
+		if w.FCMOptions != nil {
+			o := *w.FCMOptions
+			w.FCMOptions = &o
+		}
+		m.Webpush = &w
+	}
+	return &m
+}
+
+func cloneMap(in map[string]string) map[string]string {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+func TestRender(t *testing.T) {
+	proto := &messaging.Message{
+		Topic: "ignored",
+		Notification: &messaging.Notification{
+			Title: "Hi {{.Name}}",
+			Body:  "Your order of {{.Amount}} has shipped",
+		},
+		Data: map[string]string{
+			"link":   "myapp://orders/{{.OrderID}}",
+			"static": "unchanged",
+		},
+		Webpush: &messaging.WebpushConfig{
+			FCMOptions: &messaging.WebpushFCMOptions{Link: "https://example.com/orders/{{.OrderID}}"},
+		},
+	}
+	tmpl, err := New("order-shipped", proto)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msg, err := tmpl.RenderTo(Recipient{
+		Token: "device",
+		Vars:  map[string]any{"Name": "Ada", "Amount": "$12", "OrderID": 42},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.Token != "device" || msg.Topic != "" {
+		t.Fatalf("unexpected target: token=%q topic=%q", msg.Token, msg.Topic)
+	}
+	if msg.Notification.Title != "Hi Ada" || msg.Notification.Body != "Your order of $12 has shipped" {
+		t.Fatalf("unexpected notification: %+v", msg.Notification)
+	}
+	if msg.Data["link"] != "myapp://orders/42" || msg.Data["static"] != "unchanged" {
+		t.Fatalf("unexpected data: %v", msg.Data)
+	}
+	if msg.Webpush.FCMOptions.Link != "https://example.com/orders/42" {
+		t.Fatalf("unexpected link: %q", msg.Webpush.FCMOptions.Link)
+	}
+
+	// The template's own message must stay untouched.
+	if proto.Notification.Title != "Hi {{.Name}}" || proto.Data["link"] != "myapp://orders/{{.OrderID}}" {
+		t.Fatal("Render modified the template message")
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	if _, err := New("bad", &messaging.Message{Data: map[string]string{"k": "{{.Name"}}); err == nil {
+		t.Fatal("expected parse error, got nil")
+	}
+
+	tmpl := Must(New("greeting", &messaging.Message{
+		Notification: &messaging.Notification{Title: "Hi {{.Name}}"},
+	}))
+	_, err := tmpl.Render(map[string]any{})
+	if err == nil || !strings.Contains(err.Error(), "notification.title") {
+		t.Fatalf("expected missing key error naming the field, got %v", err)
+	}
+}
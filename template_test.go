@@ -0,0 +1,60 @@
+package fcm_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"firebase.google.com/go/v4/messaging"
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+	"github.com/appleboy/go-fcm/template"
+)
+
+func TestSendTemplate(t *testing.T) {
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+
+	client, err := fcm.NewClient(
+		context.Background(),
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tmpl := template.Must(template.New("greeting", &messaging.Message{
+		Notification: &messaging.Notification{Title: "Hi {{.Name}}"},
+	}))
+
+	// More renderable recipients than one SendEach call accepts, with one
+	// render failure.
+	recipients := make([]template.Recipient, 502)
+	for i := range recipients {
+		recipients[i] = template.Recipient{
+			Token: fmt.Sprintf("token-%d", i),
+			Vars:  map[string]string{"Name": fmt.Sprintf("user %d", i)},
+		}
+	}
+	recipients[7].Vars = map[string]string{}
+
+	resp, err := client.SendTemplate(context.Background(), tmpl, recipients)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.SuccessCount != 501 || resp.FailureCount != 1 || len(resp.Responses) != 502 {
+		t.Fatalf("unexpected counts: success=%d failure=%d responses=%d",
+			resp.SuccessCount, resp.FailureCount, len(resp.Responses))
+	}
+	var renderErr *fcm.RenderError
+	if !errors.As(resp.Responses[7].Error, &renderErr) || renderErr.Index != 7 {
+		t.Fatalf("expected render error for recipient 7, got %v", resp.Responses[7].Error)
+	}
+
+	delivered := srv.DeliveredTo("token-500")
+	if len(delivered) != 1 || delivered[0].Message.Notification.Title != "Hi user 500" {
+		t.Fatalf("unexpected delivery for token-500: %+v", delivered)
+	}
+}
@@ -0,0 +1,141 @@
+package fcm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// ReasonSenderIDMismatch means the token belongs to a different sender, so
+// this project can never deliver to it.
+const ReasonSenderIDMismatch InvalidReason = "SENDER_ID_MISMATCH"
+
+// validateTokensConcurrency bounds the dry-run batches ValidateTokens keeps in
+// flight. Each batch already fans out to the SDK's own workers.
+const validateTokensConcurrency = 4
+
+// InvalidToken is a token FCM rejected during ValidateTokens.
+type InvalidToken struct {
+	Token  string
+	Reason InvalidReason
+	Err    error
+}
+
+// InconclusiveToken is a token ValidateTokens could not check because of a
+// transient or configuration error; it should be checked again later.
+type InconclusiveToken struct {
+	Token string
+	Err   error
+}
+
+// TokenValidation is the result of ValidateTokens. Each list keeps the input
+// order.
+type TokenValidation struct {
+	Valid        []string
+	Invalid      []InvalidToken
+	Inconclusive []InconclusiveToken
+}
+
+// classifyTokenError reports whether err proves the token it was sent to
+// invalid, and why.
+func classifyTokenError(err error) (InvalidReason, bool) {
+	if reason, ok := invalidTokenReason(err); ok {
+		return reason, true
+	}
+	if messaging.IsSenderIDMismatch(err) {
+		return ReasonSenderIDMismatch, true
+	}
+	return "", false
+}
+
+// ValidateTokens checks tokens with dry-run sends, so no device is notified.
+// Tokens are sent in batches of 500 through SendMulticastDryRun, with a few
+// batches in flight at a time. Tokens ValidateToken rejects are invalid for
+// ReasonInvalidArgument without being sent. Tokens FCM rejects as
+// UNREGISTERED, INVALID_ARGUMENT or SENDER_ID_MISMATCH are invalid; tokens
+// whose check failed for any other reason, or whose whole batch failed, are
+// inconclusive. The returned error is only non-nil when ctx is done.
+func (c *Client) ValidateTokens(ctx context.Context, tokens []string) (*TokenValidation, error) {
+	var (
+		batches = (len(tokens) + maxBatchSize - 1) / maxBatchSize
+		results = make([]TokenValidation, batches)
+		sem     = make(chan struct{}, validateTokensConcurrency)
+		wg      sync.WaitGroup
+	)
+	for b := range batches {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			start := b * maxBatchSize
+			end := min(start+maxBatchSize, len(tokens))
+			results[b] = c.validateBatch(ctx, tokens[start:end])
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	v := &TokenValidation{}
+	for _, r := range results {
+		v.Valid = append(v.Valid, r.Valid...)
+		v.Invalid = append(v.Invalid, r.Invalid...)
+		v.Inconclusive = append(v.Inconclusive, r.Inconclusive...)
+	}
+	return v, nil
+}
+
+func (c *Client) validateBatch(ctx context.Context, tokens []string) TokenValidation {
+	// Tokens ValidateToken rejects are invalid without a round trip, and would
+	// fail the whole dry run.
+	malformed := make([]error, len(tokens))
+	var send []string
+	for i, token := range tokens {
+		if malformed[i] = ValidateToken(token); malformed[i] == nil {
+			send = append(send, token)
+		}
+	}
+	var (
+		resp *messaging.BatchResponse
+		err  error
+	)
+	if len(send) > 0 {
+		resp, err = c.SendMulticastDryRun(ctx, &messaging.MulticastMessage{Tokens: send})
+		if err == nil && len(resp.Responses) != len(send) {
+			err = fmt.Errorf("got %d responses for %d tokens", len(resp.Responses), len(send))
+		}
+	}
+
+	var v TokenValidation
+	next := 0
+	for i, token := range tokens {
+		if malformed[i] != nil {
+			v.Invalid = append(v.Invalid, InvalidToken{Token: token, Reason: ReasonInvalidArgument, Err: malformed[i]})
+			continue
+		}
+		if err != nil {
+			v.Inconclusive = append(v.Inconclusive, InconclusiveToken{Token: token, Err: err})
+			continue
+		}
+		r := resp.Responses[next]
+		next++
+		reason, invalid := classifyTokenError(r.Error)
+		switch {
+		case r.Success:
+			v.Valid = append(v.Valid, token)
+		case invalid:
+			v.Invalid = append(v.Invalid, InvalidToken{Token: token, Reason: reason, Err: r.Error})
+		default:
+			v.Inconclusive = append(v.Inconclusive, InconclusiveToken{Token: token, Err: r.Error})
+		}
+	}
+	return v
+}
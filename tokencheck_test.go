@@ -0,0 +1,58 @@
+package fcm_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+)
+
+func TestValidateTokens(t *testing.T) {
+	ctx := context.Background()
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+
+	tokens := make([]string, 1200)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("token%04d", i)
+	}
+	tokens[1] = ""
+	tokens[2] = "bad token"
+	srv.FailToken(tokens[3], "UNREGISTERED")
+	srv.FailToken(tokens[700], "SENDER_ID_MISMATCH")
+	srv.FailToken(tokens[1100], "QUOTA_EXCEEDED")
+
+	client, err := fcm.NewClient(ctx, fcm.WithProjectID("test"), fcm.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v, err := client.ValidateTokens(ctx, tokens)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(v.Valid) != 1195 || v.Valid[0] != tokens[0] || v.Valid[1194] != tokens[1199] {
+		t.Fatalf("unexpected valid tokens: %d", len(v.Valid))
+	}
+	// Malformed tokens are rejected locally and do not fail their batch.
+	if len(v.Invalid) != 4 ||
+		v.Invalid[0].Token != tokens[1] || v.Invalid[0].Reason != fcm.ReasonInvalidArgument ||
+		v.Invalid[1].Token != tokens[2] || v.Invalid[1].Reason != fcm.ReasonInvalidArgument ||
+		v.Invalid[2].Token != tokens[3] || v.Invalid[2].Reason != fcm.ReasonUnregistered ||
+		v.Invalid[3].Token != tokens[700] || v.Invalid[3].Reason != fcm.ReasonSenderIDMismatch {
+		t.Fatalf("unexpected invalid tokens: %+v", v.Invalid)
+	}
+	if len(v.Inconclusive) != 1 || v.Inconclusive[0].Token != tokens[1100] {
+		t.Fatalf("unexpected inconclusive tokens: %+v", v.Inconclusive)
+	}
+	for _, m := range srv.Messages() {
+		if m.Message.Token == tokens[2] {
+			t.Fatal("expected malformed tokens not to be sent")
+		}
+		if !m.DryRun {
+			t.Fatal("expected only dry-run sends")
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package fcm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// InvalidReason classifies why FCM rejected a registration token.
+type InvalidReason string
+
+// Reasons reported for tokens FCM will never deliver to again.
+const (
+	// ReasonUnregistered means the app was uninstalled or the token expired.
+	ReasonUnregistered InvalidReason = "UNREGISTERED"
+	// ReasonInvalidArgument means FCM rejected the token as malformed. FCM
+	// uses the same error for invalid payloads, so a Client only prunes for it
+	// when the message payload passes the local checks.
+	ReasonInvalidArgument InvalidReason = "INVALID_ARGUMENT"
+)
+
+// invalidTokenReason reports whether err means the token a message was sent
+// to is dead, and why.
+func invalidTokenReason(err error) (InvalidReason, bool) {
+	switch {
+	case messaging.IsUnregistered(err):
+		return ReasonUnregistered, true
+	case messaging.IsInvalidArgument(err):
+		return ReasonInvalidArgument, true
+	}
+	return "", false
+}
+
+// TokenStore keeps the registration tokens of each owner, typically a user
+// with one token per device. Attach one to a Client with WithTokenStore to
+// have tokens FCM reports as dead pruned automatically.
+type TokenStore interface {
+	// Add registers token for owner, moving it if it belonged to another
+	// owner and clearing any invalid mark.
+	Add(ctx context.Context, owner, token string) error
+	// Remove forgets token. Removing an unknown token is not an error.
+	Remove(ctx context.Context, token string) error
+	// ListByOwner returns the valid tokens of owner.
+	ListByOwner(ctx context.Context, owner string) ([]string, error)
+	// MarkInvalid flags token as rejected by FCM for reason, excluding it
+	// from ListByOwner. Marking an unknown token is not an error.
+	MarkInvalid(ctx context.Context, token string, reason InvalidReason) error
+}
+
+// TokenActivityStore is a TokenStore that also keeps the activity of each
+// token, so stale tokens can be detected. When the TokenStore attached to a
+// Client implements it, successful sends are recorded with MarkSent and a
+// StalenessPolicy can be applied.
+type TokenActivityStore interface {
+	TokenStore
+	// MarkSent records that a message was accepted for tokens at the given
+	// time. Unknown tokens are ignored.
+	MarkSent(ctx context.Context, tokens []string, at time.Time) error
+	// Lookup returns the records of the known tokens among tokens.
+	Lookup(ctx context.Context, tokens []string) (map[string]TokenRecord, error)
+	// List returns every record, including invalid ones.
+	List(ctx context.Context) ([]TokenRecord, error)
+}
+
+// TokenRecord is the state a MemoryTokenStore or FileTokenStore keeps for a
+// registration token.
+type TokenRecord struct {
+	Token string `json:"token"`
+	Owner string `json:"owner"`
+	// Invalid is the reason FCM rejected the token, or empty while it is valid.
+	Invalid InvalidReason `json:"invalid,omitempty"`
+	// RegisteredAt is when the token was first added.
+	RegisteredAt time.Time `json:"registered_at"`
+	// RefreshedAt is when the app last reported the token again.
+	RefreshedAt time.Time `json:"refreshed_at"`
+	// LastSentAt is when a message was last accepted for the token, or zero.
+	LastSentAt time.Time `json:"last_sent_at,omitzero"`
+}
+
+// LastSeen returns the latest time the app registered or refreshed the
+// token. Sends do not count: FCM accepts messages for stale tokens too, so
+// LastSentAt says nothing about the device.
+func (r TokenRecord) LastSeen() time.Time {
+	if r.RefreshedAt.After(r.RegisteredAt) {
+		return r.RefreshedAt
+	}
+	return r.RegisteredAt
+}
+
+// trackTokens reports the outcome of sending messages to the Client's
+// TokenStore: tokens whose response failed with an invalid-token error are
+// pruned, and, when the store is a TokenActivityStore, tokens whose response
+// succeeded are marked as sent. messages[i] is the message of
+// resp.Responses[i]; entries without a token are skipped.
+//
+// Since FCM also reports invalid payloads as INVALID_ARGUMENT, a token is only
+// pruned for it when the payload of its message passes the local checks and,
+// in a batch of several messages, not every response failed with it.
+func (c *Client) trackTokens(ctx context.Context, messages []*messaging.Message, resp *messaging.BatchResponse) error {
+	if c.tokenStore == nil || resp == nil {
+		return nil
+	}
+	allInvalid := len(resp.Responses) > 1
+	for _, r := range resp.Responses {
+		if r == nil || r.Success || !messaging.IsInvalidArgument(r.Error) {
+			allInvalid = false
+			break
+		}
+	}
+	var (
+		errs []error
+		sent []string
+	)
+	for i, r := range resp.Responses {
+		if i >= len(messages) || messages[i] == nil || messages[i].Token == "" || r == nil {
+			continue
+		}
+		token := messages[i].Token
+		if r.Success {
+			sent = append(sent, token)
+			continue
+		}
+		reason, ok := invalidTokenReason(r.Error)
+		if !ok {
+			continue
+		}
+		if reason == ReasonInvalidArgument && (allInvalid || validatePayload(messages[i]) != nil) {
+			continue
+		}
+		var err error
+		if c.removeInvalid {
+			err = c.tokenStore.Remove(ctx, token)
+		} else {
+			err = c.tokenStore.MarkInvalid(ctx, token, reason)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot prune token at index %d: %w", i, err))
+		}
+	}
+	if activity, ok := c.tokenStore.(TokenActivityStore); ok && len(sent) > 0 {
+		if err := activity.MarkSent(ctx, sent, c.clock.Now()); err != nil {
+			errs = append(errs, fmt.Errorf("cannot record sent tokens: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MemoryTokenStore is a TokenActivityStore held in memory. It is safe for
+// concurrent use.
+type MemoryTokenStore struct {
+	clock Clock
+
+	mu     sync.Mutex
+	tokens map[string]*TokenRecord
+}
+
+// NewMemoryTokenStore returns an empty MemoryTokenStore that timestamps the
+// tokens it adds with clock. A nil clock uses the system clock.
+func NewMemoryTokenStore(clock Clock) *MemoryTokenStore {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &MemoryTokenStore{clock: clock, tokens: map[string]*TokenRecord{}}
+}
+
+// Add implements TokenStore. Adding a known token refreshes it.
+func (s *MemoryTokenStore) Add(_ context.Context, owner, token string) error {
+	if token == "" {
+		return errors.New("token must not be empty")
+	}
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.tokens[token]
+	if !ok {
+		r = &TokenRecord{Token: token, RegisteredAt: now}
+		s.tokens[token] = r
+	}
+	r.Owner = owner
+	r.Invalid = ""
+	r.RefreshedAt = now
+	return nil
+}
+
+// Put stores r as is, replacing any record of the same token. It is meant
+// for importing tokens along with their history.
+func (s *MemoryTokenStore) Put(_ context.Context, r TokenRecord) error {
+	if r.Token == "" {
+		return errors.New("token must not be empty")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[r.Token] = &r
+	return nil
+}
+
+// Remove implements TokenStore.
+func (s *MemoryTokenStore) Remove(_ context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, token)
+	return nil
+}
+
+// ListByOwner implements TokenStore. Tokens are returned sorted.
+func (s *MemoryTokenStore) ListByOwner(_ context.Context, owner string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var tokens []string
+	for _, r := range s.tokens {
+		if r.Owner == owner && r.Invalid == "" {
+			tokens = append(tokens, r.Token)
+		}
+	}
+	sort.Strings(tokens)
+	return tokens, nil
+}
+
+// MarkInvalid implements TokenStore.
+func (s *MemoryTokenStore) MarkInvalid(_ context.Context, token string, reason InvalidReason) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.tokens[token]; ok {
+		r.Invalid = reason
+	}
+	return nil
+}
+
+// MarkSent implements TokenActivityStore.
+func (s *MemoryTokenStore) MarkSent(_ context.Context, tokens []string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range tokens {
+		if r, ok := s.tokens[token]; ok && at.After(r.LastSentAt) {
+			r.LastSentAt = at
+		}
+	}
+	return nil
+}
+
+// Lookup implements TokenActivityStore.
+func (s *MemoryTokenStore) Lookup(_ context.Context, tokens []string) (map[string]TokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make(map[string]TokenRecord, len(tokens))
+	for _, token := range tokens {
+		if r, ok := s.tokens[token]; ok {
+			records[token] = *r
+		}
+	}
+	return records, nil
+}
+
+// List implements TokenActivityStore. Records are returned sorted by token.
+func (s *MemoryTokenStore) List(context.Context) ([]TokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listLocked(), nil
+}
+
+func (s *MemoryTokenStore) listLocked() []TokenRecord {
+	records := make([]TokenRecord, 0, len(s.tokens))
+	for _, r := range s.tokens {
+		records = append(records, *r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Token < records[j].Token })
+	return records
+}
+
+// FileTokenStore is a MemoryTokenStore persisted as a JSON array of
+// TokenRecord in a single file, rewritten atomically after every change. It
+// suits small deployments and tests; it is safe for concurrent use within one
+// process only.
+type FileTokenStore struct {
+	*MemoryTokenStore
+	path string
+	// mu serializes changes with the file writes that follow them.
+	mu sync.Mutex
+}
+
+// OpenFileTokenStore loads the FileTokenStore kept at path, starting empty if
+// the file does not exist yet. Like NewMemoryTokenStore, it timestamps added
+// tokens with clock, or the system clock when it is nil.
+func OpenFileTokenStore(path string, clock Clock) (*FileTokenStore, error) {
+	s := &FileTokenStore{MemoryTokenStore: NewMemoryTokenStore(clock), path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read token store: %w", err)
+	}
+	var records []TokenRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("cannot decode token store %s: %w", path, err)
+	}
+	for _, r := range records {
+		s.tokens[r.Token] = &r
+	}
+	return s, nil
+}
+
+// Add implements TokenStore.
+func (s *FileTokenStore) Add(ctx context.Context, owner, token string) error {
+	return s.update(func() error { return s.MemoryTokenStore.Add(ctx, owner, token) })
+}
+
+// Put stores r as is, replacing any record of the same token.
+func (s *FileTokenStore) Put(ctx context.Context, r TokenRecord) error {
+	return s.update(func() error { return s.MemoryTokenStore.Put(ctx, r) })
+}
+
+// Remove implements TokenStore.
+func (s *FileTokenStore) Remove(ctx context.Context, token string) error {
+	return s.update(func() error { return s.MemoryTokenStore.Remove(ctx, token) })
+}
+
+// MarkInvalid implements TokenStore.
+func (s *FileTokenStore) MarkInvalid(ctx context.Context, token string, reason InvalidReason) error {
+	return s.update(func() error { return s.MemoryTokenStore.MarkInvalid(ctx, token, reason) })
+}
+
+// MarkSent implements TokenActivityStore.
+func (s *FileTokenStore) MarkSent(ctx context.Context, tokens []string, at time.Time) error {
+	return s.update(func() error { return s.MemoryTokenStore.MarkSent(ctx, tokens, at) })
+}
+
+// update applies change and writes the result to a temporary file that then
+// replaces the store file.
+func (s *FileTokenStore) update(change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := change(); err != nil {
+		return err
+	}
+
+	s.MemoryTokenStore.mu.Lock()
+	records := s.listLocked()
+	s.MemoryTokenStore.mu.Unlock()
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("cannot write token store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot write token store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot write token store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("cannot write token store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package fcm_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"firebase.google.com/go/v4/messaging"
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+)
+
+func TestTokenStorePruning(t *testing.T) {
+	ctx := context.Background()
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+	srv.FailToken("gone", "UNREGISTERED")
+	srv.FailToken("broken", "INVALID_ARGUMENT")
+	srv.FailToken("busy", "QUOTA_EXCEEDED")
+
+	for _, remove := range []bool{false, true} {
+		store := fcm.NewMemoryTokenStore(nil)
+		for _, token := range []string{"ok", "gone", "broken", "busy"} {
+			if err := store.Add(ctx, "alice", token); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		client, err := fcm.NewClient(
+			ctx,
+			fcm.WithProjectID("test"),
+			fcm.WithHTTPClient(srv.Client()),
+			fcm.WithTokenStore(store, remove),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		resp, err := client.Send(ctx,
+			&messaging.Message{Token: "ok"},
+			&messaging.Message{Token: "gone"},
+			&messaging.Message{Topic: "news"},
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.FailureCount != 1 {
+			t.Fatalf("expected 1 failure, got %d", resp.FailureCount)
+		}
+		resp, err = client.SendMulticast(ctx, &messaging.MulticastMessage{
+			Tokens: []string{"ok", "broken", "busy"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.FailureCount != 2 {
+			t.Fatalf("expected 2 failures, got %d", resp.FailureCount)
+		}
+
+		tokens, _ := store.ListByOwner(ctx, "alice")
+		if want := []string{"busy", "ok"}; !reflect.DeepEqual(tokens, want) {
+			t.Fatalf("remove=%v: ListByOwner() = %v, want %v", remove, tokens, want)
+		}
+		records, _ := store.Lookup(ctx, []string{"ok", "gone", "broken"})
+		r, ok := records["gone"]
+		if remove && ok {
+			t.Fatal("expected the unregistered token to be removed")
+		}
+		if !remove && r.Invalid != fcm.ReasonUnregistered {
+			t.Fatalf("expected the token to be marked unregistered, got %q", r.Invalid)
+		}
+		if !remove && records["broken"].Invalid != fcm.ReasonInvalidArgument {
+			t.Fatalf("expected the token to be marked invalid, got %q", records["broken"].Invalid)
+		}
+		if records["ok"].LastSentAt.IsZero() {
+			t.Fatal("expected the successful send to be recorded")
+		}
+	}
+}
+
+func TestTokenStoreKeepsTokensOnPayloadErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+	srv.FailToken("a", "INVALID_ARGUMENT")
+	srv.FailToken("b", "INVALID_ARGUMENT")
+
+	store := fcm.NewMemoryTokenStore(nil)
+	for _, token := range []string{"ok", "a", "b"} {
+		if err := store.Add(ctx, "alice", token); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+		fcm.WithTokenStore(store, true),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Every response failing with INVALID_ARGUMENT points at the payload.
+	if _, err := client.SendMulticast(ctx, &messaging.MulticastMessage{Tokens: []string{"a", "b"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// So does a message whose payload fails the local checks.
+	oversized := map[string]string{"blob": strings.Repeat("x", fcm.MaxPayloadSize)}
+	if _, err := client.Send(ctx,
+		&messaging.Message{Token: "a", Data: oversized},
+		&messaging.Message{Token: "ok"},
+	); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokens, _ := store.ListByOwner(ctx, "alice")
+	if want := []string{"a", "b", "ok"}; !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("ListByOwner() = %v, want %v", tokens, want)
+	}
+
+	if _, err := client.Send(ctx,
+		&messaging.Message{Token: "a"},
+		&messaging.Message{Token: "ok"},
+	); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokens, _ = store.ListByOwner(ctx, "alice")
+	if want := []string{"b", "ok"}; !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("ListByOwner() = %v, want %v", tokens, want)
+	}
+}
+
+func TestTokenStorePrunesInvalidArgumentTokens(t *testing.T) {
+	ctx := context.Background()
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+	srv.FailToken("a", "INVALID_ARGUMENT")
+	srv.FailToken("bad token", "INVALID_ARGUMENT")
+	srv.FailToken("c", "INVALID_ARGUMENT")
+
+	store := fcm.NewMemoryTokenStore(nil)
+	for _, token := range []string{"ok", "a", "bad token", "c"} {
+		if err := store.Add(ctx, "alice", token); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+		fcm.WithTokenStore(store, true),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A single message, and a malformed token next to a valid one.
+	if _, err := client.Send(ctx, &messaging.Message{Token: "a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.SendMulticast(ctx, &messaging.MulticastMessage{Tokens: []string{"bad token", "ok"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.SendMulticast(ctx, &messaging.MulticastMessage{Tokens: []string{"c"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokens, _ := store.ListByOwner(ctx, "alice")
+	if want := []string{"ok"}; !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("ListByOwner() = %v, want %v", tokens, want)
+	}
+}
+
+func TestFileTokenStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+
+	store, err := fcm.OpenFileTokenStore(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = store.Add(ctx, "alice", "a1")
+	_ = store.Add(ctx, "alice", "a2")
+	_ = store.Add(ctx, "bob", "b1")
+	_ = store.MarkInvalid(ctx, "a2", fcm.ReasonUnregistered)
+	if err := store.Remove(ctx, "b1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reopened, err := fcm.OpenFileTokenStore(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want, _ := store.List(ctx)
+	got, _ := reopened.List(ctx)
+	if len(got) != 2 || got[1].Invalid != fcm.ReasonUnregistered {
+		t.Fatalf("List() = %+v", got)
+	}
+	for i := range got {
+		if got[i].Token != want[i].Token || !got[i].RegisteredAt.Equal(want[i].RegisteredAt) {
+			t.Fatalf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	tokens, _ := reopened.ListByOwner(ctx, "alice")
+	if !reflect.DeepEqual(tokens, []string{"a1"}) {
+		t.Fatalf("ListByOwner() = %v", tokens)
+	}
+}
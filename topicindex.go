@@ -0,0 +1,194 @@
+package fcm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/appleboy/go-fcm/condition"
+)
+
+// TopicIndex mirrors topic memberships locally, since FCM has no API to list
+// the subscribers of a topic. Attached to a Client with WithTopicIndex, it
+// records every token that SubscribeTopic or UnsubscribeTopic changed
+// successfully; memberships changed by other means are not seen, so its
+// answers are estimates. The zero value is an empty index ready to use. A
+// TopicIndex is safe for concurrent use and implements TopicMembershipSource.
+type TopicIndex struct {
+	mu     sync.RWMutex
+	tokens map[string]map[string]struct{} // token -> topics
+}
+
+// NewTopicIndex returns an empty TopicIndex.
+func NewTopicIndex() *TopicIndex {
+	return &TopicIndex{tokens: map[string]map[string]struct{}{}}
+}
+
+// Subscribe records tokens as subscribed to topic.
+func (x *TopicIndex) Subscribe(topic string, tokens ...string) {
+	topic = NormalizeTopic(topic)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.tokens == nil {
+		x.tokens = map[string]map[string]struct{}{}
+	}
+	for _, token := range tokens {
+		topics := x.tokens[token]
+		if topics == nil {
+			topics = map[string]struct{}{}
+			x.tokens[token] = topics
+		}
+		topics[topic] = struct{}{}
+	}
+}
+
+// Unsubscribe records tokens as unsubscribed from topic.
+func (x *TopicIndex) Unsubscribe(topic string, tokens ...string) {
+	topic = NormalizeTopic(topic)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, token := range tokens {
+		delete(x.tokens[token], topic)
+		if len(x.tokens[token]) == 0 {
+			delete(x.tokens, token)
+		}
+	}
+}
+
+// TopicsOf returns the sorted topics token is subscribed to.
+func (x *TopicIndex) TopicsOf(token string) []string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return sortedSet(x.tokens[token])
+}
+
+// Topics implements TopicMembershipSource.
+func (x *TopicIndex) Topics(_ context.Context, token string) ([]string, error) {
+	return x.TopicsOf(token), nil
+}
+
+// TokensIn returns the sorted tokens subscribed to topic.
+func (x *TopicIndex) TokensIn(topic string) []string {
+	topic = NormalizeTopic(topic)
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	var tokens []string
+	for token, topics := range x.tokens {
+		if _, ok := topics[topic]; ok {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// Audience returns the sorted tokens whose recorded subscriptions satisfy the
+// topic condition cond, estimating who a message sent with it would reach.
+func (x *TopicIndex) Audience(cond string) ([]string, error) {
+	expr, err := condition.Parse(cond)
+	if err != nil {
+		return nil, err
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	var tokens []string
+	for token, topics := range x.tokens {
+		if condition.Evaluate(expr, sortedSet(topics)) {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Strings(tokens)
+	return tokens, nil
+}
+
+// Snapshot returns the recorded topics of every token. It is the desired
+// state to pass to ReconcileTopics.
+func (x *TopicIndex) Snapshot() map[string][]string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	snapshot := make(map[string][]string, len(x.tokens))
+	for token, topics := range x.tokens {
+		snapshot[token] = sortedSet(topics)
+	}
+	return snapshot
+}
+
+// MarshalJSON encodes the index as its Snapshot, so it can be persisted.
+func (x *TopicIndex) MarshalJSON() ([]byte, error) {
+	return json.Marshal(x.Snapshot())
+}
+
+// UnmarshalJSON replaces the content of the index with a decoded Snapshot.
+func (x *TopicIndex) UnmarshalJSON(data []byte) error {
+	var snapshot map[string][]string
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	tokens := make(map[string]map[string]struct{}, len(snapshot))
+	for token, topics := range snapshot {
+		if len(topics) == 0 {
+			continue
+		}
+		set := make(map[string]struct{}, len(topics))
+		for _, topic := range topics {
+			set[NormalizeTopic(topic)] = struct{}{}
+		}
+		tokens[token] = set
+	}
+	// Swap the decoded index in at once, so readers never see it half loaded.
+	x.mu.Lock()
+	x.tokens = tokens
+	x.mu.Unlock()
+	return nil
+}
+
+// record applies the outcome of a topic management call for tokens, skipping
+// the tokens FCM reported errors for.
+func (x *TopicIndex) record(tokens []string, topic string, resp *messaging.TopicManagementResponse, subscribe bool) {
+	failed := map[int]bool{}
+	if resp != nil {
+		for _, e := range resp.Errors {
+			failed[e.Index] = true
+		}
+	}
+	var ok []string
+	for i, token := range tokens {
+		if !failed[i] {
+			ok = append(ok, token)
+		}
+	}
+	if subscribe {
+		x.Subscribe(topic, ok...)
+	} else {
+		x.Unsubscribe(topic, ok...)
+	}
+}
+
+// ResubscribeTopics subscribes every token of the Client's TopicIndex to its
+// recorded topics again, for instance to restore memberships after a
+// disaster, and returns the executed plan with its failures recorded like
+// ApplyTopicPlan.
+func (c *Client) ResubscribeTopics(ctx context.Context) (*TopicPlan, error) {
+	if c.topicIndex == nil {
+		return nil, errors.New("client has no TopicIndex attached")
+	}
+	snapshot := c.topicIndex.Snapshot()
+	plan := &TopicPlan{Diffs: make([]TopicDiff, 0, len(snapshot))}
+	for token, topics := range snapshot {
+		plan.Diffs = append(plan.Diffs, TopicDiff{Token: token, Subscribe: topics})
+	}
+	sort.Slice(plan.Diffs, func(i, j int) bool { return plan.Diffs[i].Token < plan.Diffs[j].Token })
+	return plan, c.ApplyTopicPlan(ctx, plan)
+}
+
+func sortedSet(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
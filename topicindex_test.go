@@ -0,0 +1,111 @@
+package fcm_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	fcm "github.com/appleboy/go-fcm"
+	"github.com/appleboy/go-fcm/fcmtest"
+)
+
+func TestTopicIndex(t *testing.T) {
+	ctx := context.Background()
+	srv := fcmtest.NewServer()
+	defer srv.Close()
+	srv.FailToken("dead", "UNREGISTERED")
+
+	idx := fcm.NewTopicIndex()
+	client, err := fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(srv.Client()),
+		fcm.WithTopicIndex(idx),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := client.SubscribeTopic(ctx, []string{"t1", "t2", "dead"}, "/topics/en"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.SubscribeTopic(ctx, []string{"t1", "t3"}, "sports"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.UnsubscribeTopic(ctx, []string{"t2"}, "en"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := idx.TokensIn("en"); !reflect.DeepEqual(got, []string{"t1"}) {
+		t.Fatalf("TokensIn(en) = %v", got)
+	}
+	if got := idx.TopicsOf("t1"); !reflect.DeepEqual(got, []string{"en", "sports"}) {
+		t.Fatalf("TopicsOf(t1) = %v", got)
+	}
+	if got := idx.TopicsOf("dead"); len(got) != 0 {
+		t.Fatalf("expected the failed token not to be recorded, got %v", got)
+	}
+	audience, err := idx.Audience("'sports' in topics && !('en' in topics)")
+	if err != nil || !reflect.DeepEqual(audience, []string{"t3"}) {
+		t.Fatalf("Audience() = %v, %v", audience, err)
+	}
+
+	// Persist the index and restore every membership on a fresh backend.
+	data, err := json.Marshal(idx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored := fcm.NewTopicIndex()
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(restored.Snapshot(), idx.Snapshot()) {
+		t.Fatalf("restored snapshot = %v, want %v", restored.Snapshot(), idx.Snapshot())
+	}
+
+	fresh := fcmtest.NewServer()
+	defer fresh.Close()
+	client, err = fcm.NewClient(
+		ctx,
+		fcm.WithProjectID("test"),
+		fcm.WithHTTPClient(fresh.Client()),
+		fcm.WithTopicIndex(restored),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plan, err := client.ResubscribeTopics(ctx)
+	if err != nil || len(plan.Failed()) != 0 {
+		t.Fatalf("unexpected result: %v %v", plan.Failed(), err)
+	}
+	if got := fresh.Subscriptions("t1"); !reflect.DeepEqual(got, []string{"en", "sports"}) {
+		t.Fatalf("Subscriptions(t1) = %v", got)
+	}
+	if got := fresh.TopicMembers("sports"); !reflect.DeepEqual(got, []string{"t1", "t3"}) {
+		t.Fatalf("TopicMembers(sports) = %v", got)
+	}
+}
+
+func TestTopicIndexZeroValue(t *testing.T) {
+	var index fcm.TopicIndex
+	if got := index.TokensIn("news"); len(got) != 0 {
+		t.Fatalf("TokensIn() = %v, want none", got)
+	}
+	index.Unsubscribe("news", "t1")
+	index.Subscribe("/topics/news", "t1")
+	if got := index.TopicsOf("t1"); !reflect.DeepEqual(got, []string{"news"}) {
+		t.Fatalf("TopicsOf() = %v, want [news]", got)
+	}
+}
+
+func TestTopicIndexUnmarshalKeepsIndexOnError(t *testing.T) {
+	index := fcm.NewTopicIndex()
+	index.Subscribe("news", "t1")
+	if err := index.UnmarshalJSON([]byte(`{"t2": ["sports"], "t3": 5}`)); err == nil {
+		t.Fatal("expected a decode error, got nil")
+	}
+	if got := index.Snapshot(); !reflect.DeepEqual(got, map[string][]string{"t1": {"news"}}) {
+		t.Fatalf("Snapshot() = %v, want the index left untouched", got)
+	}
+}
@@ -0,0 +1,308 @@
+package fcm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/appleboy/go-fcm/condition"
+)
+
+// MaxPayloadSize is the largest message payload, in bytes, FCM accepts.
+const MaxPayloadSize = 4096
+
+// reservedDataKeys are data keys FCM rejects outright. Keys starting with one
+// of reservedDataKeyPrefixes are rejected as well.
+var (
+	reservedDataKeys = map[string]bool{
+		"from":         true,
+		"message_type": true,
+		"collapse_key": true,
+	}
+	reservedDataKeyPrefixes = []string{"google.", "gcm."}
+)
+
+// topicPattern matches a topic name without its "/topics/" prefix and
+// tokenPattern the characters found in registration tokens.
+var (
+	topicPattern = regexp.MustCompile(`^[a-zA-Z0-9-_.~%]{1,900}$`)
+	tokenPattern = regexp.MustCompile(`^[a-zA-Z0-9_:-]+$`)
+)
+
+// maxTokenLength bounds the length of a plausible registration token.
+const maxTokenLength = 4096
+
+// InvalidDataError reports a data payload entry FCM would reject.
+type InvalidDataError struct {
+	Key    string
+	Reason string
+}
+
+func (e *InvalidDataError) Error() string {
+	return fmt.Sprintf("invalid data key %q: %s", e.Key, e.Reason)
+}
+
+// FieldSize is the number of payload bytes contributed by one message field.
+type FieldSize struct {
+	Field string
+	Size  int
+}
+
+// PayloadTooLargeError reports a message whose payload exceeds
+// MaxPayloadSize. Fields lists every contributing field, largest first.
+type PayloadTooLargeError struct {
+	Size   int
+	Limit  int
+	Fields []FieldSize
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	largest := make([]string, 0, 3)
+	for i, f := range e.Fields {
+		if i == 3 {
+			break
+		}
+		largest = append(largest, fmt.Sprintf("%s (%d bytes)", f.Field, f.Size))
+	}
+	return fmt.Sprintf(
+		"message payload is %d bytes, exceeding the %d byte limit; largest fields: %s",
+		e.Size, e.Limit, strings.Join(largest, ", "),
+	)
+}
+
+// Validate checks a message locally against the FCM limits that would
+// otherwise only surface as INVALID_ARGUMENT after a round trip.
+func Validate(message *messaging.Message) error {
+	if message == nil {
+		return errors.New("message must not be nil")
+	}
+	if err := ValidateTarget(message); err != nil {
+		return err
+	}
+	return validatePayload(message)
+}
+
+// ValidateMulticast checks a multicast message like Validate.
+func ValidateMulticast(message *messaging.MulticastMessage) error {
+	if message == nil {
+		return errors.New("message must not be nil")
+	}
+	if len(message.Tokens) == 0 {
+		return errors.New("tokens must not be empty")
+	}
+	for idx, token := range message.Tokens {
+		if err := ValidateToken(token); err != nil {
+			return fmt.Errorf("invalid token at index %d: %w", idx, err)
+		}
+	}
+	return validatePayload(multicastPayload(message))
+}
+
+// ValidateTarget checks that exactly one of Token, Topic or Condition is set
+// and that the one set is well formed, using ValidateToken, ValidateTopic or
+// ValidateCondition respectively.
+func ValidateTarget(message *messaging.Message) error {
+	n := 0
+	for _, target := range []string{message.Token, message.Topic, message.Condition} {
+		if target != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return errors.New("exactly one of token, topic or condition must be set")
+	}
+
+	switch {
+	case message.Token != "":
+		return ValidateToken(message.Token)
+	case message.Topic != "":
+		return ValidateTopic(message.Topic)
+	}
+	return ValidateCondition(message.Condition)
+}
+
+// ValidateCondition checks that cond parses as an FCM topic condition and
+// stays within its limits, such as five topics per condition.
+func ValidateCondition(cond string) error {
+	expr, err := condition.Parse(cond)
+	if err != nil {
+		return err
+	}
+	return condition.Validate(expr)
+}
+
+// NormalizeTopic returns topic without its optional "/topics/" prefix, the
+// form FCM expects in the message body.
+func NormalizeTopic(topic string) string {
+	return strings.TrimPrefix(topic, "/topics/")
+}
+
+// ValidateTopic checks that topic, with or without the "/topics/" prefix,
+// matches the pattern FCM allows: 1 to 900 characters from
+// [a-zA-Z0-9-_.~%].
+func ValidateTopic(topic string) error {
+	if !topicPattern.MatchString(NormalizeTopic(topic)) {
+		return fmt.Errorf("invalid topic name: %q", topic)
+	}
+	return nil
+}
+
+// ValidateToken checks that token looks like an FCM registration token: a
+// non-empty string of at most 4096 characters from [a-zA-Z0-9_:-]. It does
+// not tell whether FCM still considers the token registered.
+func ValidateToken(token string) error {
+	if token == "" {
+		return errors.New("token must not be empty")
+	}
+	if len(token) > maxTokenLength {
+		return fmt.Errorf("token is %d characters long, exceeding %d", len(token), maxTokenLength)
+	}
+	if !tokenPattern.MatchString(token) {
+		return fmt.Errorf("token %q contains characters not allowed in registration tokens", token)
+	}
+	return nil
+}
+
+// validatePayload runs the target-independent checks shared by Validate and
+// ValidateMulticast.
+func validatePayload(message *messaging.Message) error {
+	if err := ValidateData(message.Data); err != nil {
+		return err
+	}
+	if message.Android != nil {
+		if err := ValidateData(message.Android.Data); err != nil {
+			return fmt.Errorf("android: %w", err)
+		}
+	}
+	if err := ValidatePlatforms(message); err != nil {
+		return err
+	}
+	return ValidateSize(message)
+}
+
+// ValidateData checks a data payload for empty keys, reserved keys ("from",
+// "message_type", "collapse_key" and anything prefixed with "google." or
+// "gcm.") and keys or values that are not valid UTF-8. Every offending key
+// is reported, in key order, as an *InvalidDataError.
+func ValidateData(data map[string]string) error {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, k := range keys {
+		if reason := invalidDataReason(k, data[k]); reason != "" {
+			errs = append(errs, &InvalidDataError{Key: k, Reason: reason})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func invalidDataReason(key, value string) string {
+	lower := strings.ToLower(key)
+	switch {
+	case key == "":
+		return "key must not be empty"
+	case !utf8.ValidString(key):
+		return "key is not valid UTF-8"
+	case reservedDataKeys[lower]:
+		return "key is reserved by FCM"
+	case !utf8.ValidString(value):
+		return "value is not valid UTF-8"
+	}
+	for _, prefix := range reservedDataKeyPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return fmt.Sprintf("keys prefixed with %q are reserved by FCM", prefix)
+		}
+	}
+	return ""
+}
+
+// PayloadSize returns the payload size of a message the way FCM counts it:
+// the bytes of every data key and value plus the serialized notification and
+// platform-specific blocks. Target fields are not part of the payload. The
+// per-field breakdown is sorted largest first.
+func PayloadSize(message *messaging.Message) (int, []FieldSize, error) {
+	var fields []FieldSize
+	for k, v := range message.Data {
+		fields = append(fields, FieldSize{Field: "data." + k, Size: len(k) + len(v)})
+	}
+
+	blocks := []struct {
+		name string
+		val  any
+		set  bool
+	}{
+		{"notification", message.Notification, message.Notification != nil},
+		{"android", message.Android, message.Android != nil},
+		{"apns", message.APNS, message.APNS != nil},
+		{"webpush", message.Webpush, message.Webpush != nil},
+	}
+	for _, b := range blocks {
+		if !b.set {
+			continue
+		}
+		data, err := json.Marshal(b.val)
+		if err != nil {
+			return 0, nil, fmt.Errorf("cannot encode %s: %w", b.name, err)
+		}
+		fields = append(fields, FieldSize{Field: b.name, Size: len(data)})
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].Size != fields[j].Size {
+			return fields[i].Size > fields[j].Size
+		}
+		return fields[i].Field < fields[j].Field
+	})
+
+	total := 0
+	for _, f := range fields {
+		total += f.Size
+	}
+	return total, fields, nil
+}
+
+// ValidateSize returns a *PayloadTooLargeError when the message payload
+// exceeds MaxPayloadSize.
+func ValidateSize(message *messaging.Message) error {
+	size, fields, err := PayloadSize(message)
+	if err != nil {
+		return err
+	}
+	if size > MaxPayloadSize {
+		return &PayloadTooLargeError{Size: size, Limit: MaxPayloadSize, Fields: fields}
+	}
+	return nil
+}
+
+// validateMessages runs Validate on every message, reporting the first
+// failure with its index like the Firebase SDK does.
+func validateMessages(messages []*messaging.Message) error {
+	for idx, m := range messages {
+		if err := Validate(m); err != nil {
+			return fmt.Errorf("invalid message at index %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+// multicastPayload returns the per-recipient message of a multicast message
+// without a target.
+func multicastPayload(m *messaging.MulticastMessage) *messaging.Message {
+	return &messaging.Message{
+		Data:         m.Data,
+		Notification: m.Notification,
+		Android:      m.Android,
+		Webpush:      m.Webpush,
+		APNS:         m.APNS,
+		FCMOptions:   m.FCMOptions,
+	}
+}
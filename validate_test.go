@@ -0,0 +1,174 @@
+package fcm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+func TestValidateSize(t *testing.T) {
+	msg := &messaging.Message{
+		Token: "test",
+		Data: map[string]string{
+			"big":   strings.Repeat("x", 3000),
+			"small": "y",
+		},
+		Notification: &messaging.Notification{Body: strings.Repeat("z", 1200)},
+	}
+	err := Validate(msg)
+	var tooLarge *PayloadTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("expected *PayloadTooLargeError, got %v", err)
+	}
+	if tooLarge.Fields[0].Field != "data.big" || tooLarge.Fields[1].Field != "notification" {
+		t.Fatalf("unexpected largest fields: %+v", tooLarge.Fields)
+	}
+	if !strings.Contains(err.Error(), "data.big (3003 bytes)") {
+		t.Fatalf("expected error to name the largest field, got %v", err)
+	}
+
+	msg.Data["big"] = "x"
+	if err := Validate(msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPreflightValidation(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"name": "q1w2e3r4"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(
+		context.Background(),
+		WithEndpoint(server.URL),
+		WithProjectID("test"),
+		WithTokenSource(&MockTokenSource{AccessToken: "test-token"}),
+		WithPreflightValidation(true),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	oversized := map[string]string{"blob": strings.Repeat("x", MaxPayloadSize)}
+	_, err = client.Send(
+		context.Background(),
+		&messaging.Message{Token: "ok", Data: map[string]string{"foo": "bar"}},
+		&messaging.Message{Token: "test", Data: oversized},
+	)
+	if err == nil || !strings.Contains(err.Error(), "invalid message at index 1") {
+		t.Fatalf("expected preflight error for index 1, got %v", err)
+	}
+	_, err = client.SendMulticast(
+		context.Background(),
+		&messaging.MulticastMessage{Tokens: []string{"test"}, Data: oversized},
+	)
+	var tooLarge *PayloadTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("expected *PayloadTooLargeError, got %v", err)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("expected no requests to reach FCM, got %d", n)
+	}
+
+	resp, err := client.Send(
+		context.Background(),
+		&messaging.Message{Token: "test", Data: map[string]string{"foo": "bar"}},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkSuccessfulBatchResponseForSendEach(t, resp)
+}
+
+func TestValidateData(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]string
+		want string
+	}{
+		{name: "valid", data: map[string]string{"score": "850", "googler": "yes"}},
+		{name: "empty key", data: map[string]string{"": "x"}, want: "key must not be empty"},
+		{name: "reserved", data: map[string]string{"from": "x"}, want: "reserved by FCM"},
+		{name: "reserved case", data: map[string]string{"Collapse_Key": "x"}, want: "reserved by FCM"},
+		{name: "google prefix", data: map[string]string{"google.c.a": "x"}, want: `"google."`},
+		{name: "gcm prefix", data: map[string]string{"gcm.n.e": "x"}, want: `"gcm."`},
+		{name: "invalid key", data: map[string]string{"\xff": "x"}, want: "key is not valid UTF-8"},
+		{name: "invalid value", data: map[string]string{"k": "\xfe"}, want: "value is not valid UTF-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateData(tt.data)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var dataErr *InvalidDataError
+			if !errors.As(err, &dataErr) {
+				t.Fatalf("expected *InvalidDataError, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	err := ValidateMulticast(&messaging.MulticastMessage{
+		Tokens:  []string{"a"},
+		Android: &messaging.AndroidConfig{Data: map[string]string{"message_type": "x"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "android:") {
+		t.Fatalf("expected android data error, got %v", err)
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     *messaging.Message
+		wantErr bool
+	}{
+		{name: "token", msg: &messaging.Message{Token: "abc:DEF_123-x"}},
+		{name: "topic", msg: &messaging.Message{Topic: "news"}},
+		{name: "prefixed topic", msg: &messaging.Message{Topic: "/topics/stock-GOOG"}},
+		{name: "condition", msg: &messaging.Message{Condition: "'a' in topics"}},
+		{name: "malformed condition", msg: &messaging.Message{Condition: "'a' in topics ||"}, wantErr: true},
+		{
+			name: "condition with six topics",
+			msg: &messaging.Message{
+				Condition: "'a' in topics || 'b' in topics || 'c' in topics || 'd' in topics || 'e' in topics || 'f' in topics",
+			},
+			wantErr: true,
+		},
+		{name: "no target", msg: &messaging.Message{}, wantErr: true},
+		{name: "token and topic", msg: &messaging.Message{Token: "abc", Topic: "news"}, wantErr: true},
+		{name: "topic with space", msg: &messaging.Message{Topic: "/topics/foo bar"}, wantErr: true},
+		{name: "token with space", msg: &messaging.Message{Token: " abc"}, wantErr: true},
+		{name: "oversized token", msg: &messaging.Message{Token: strings.Repeat("a", 4097)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTarget(tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	err := ValidateMulticast(&messaging.MulticastMessage{Tokens: []string{"ok", "not ok"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid token at index 1") {
+		t.Fatalf("expected invalid token error, got %v", err)
+	}
+}